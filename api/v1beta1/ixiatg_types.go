@@ -18,6 +18,7 @@ package v1beta1
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -30,12 +31,49 @@ type IxiaTGSvcPort struct {
 	//InIp     string `json:"inside_ip,omitempty"`
 	//OutIp    string `json:"outside_ip,omitempty"`
 	//NodePort int32 `json:"node_port,omitempty"`
+	// Exposure of the endpoint outside the cluster, defaults to LoadBalancer service
+	Expose *IxiaTGSvcExpose `json:"expose,omitempty"`
+}
+
+// IxiaTGSvcExpose defines how an OTG service endpoint is exposed outside the cluster
+type IxiaTGSvcExpose struct {
+	// Exposure type, one of LoadBalancer, Ingress or Gateway
+	Type string `json:"type,omitempty"`
+	// Host name used for routing; derived as <endpoint>.<namespace>.<domain> if not specified
+	Host string `json:"host,omitempty"`
+	// Domain used to derive the host name
+	Domain string `json:"domain,omitempty"`
+	// Ingress class name for Ingress exposure
+	IngressClass string `json:"ingress_class,omitempty"`
+	// Gateway name for Gateway exposure
+	Gateway string `json:"gateway,omitempty"`
+	// Gateway namespace for Gateway exposure, defaults to the node namespace
+	GatewayNamespace string `json:"gateway_namespace,omitempty"`
+	// Additional annotations for the generated Ingress or route
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
 // IxiaTGSvcPort defines the endpoint ports for network traffic for the OTG node
 type IxiaTGIntf struct {
 	Name  string `json:"name"`
 	Group string `json:"group,omitempty"`
+	// Multus NetworkAttachmentDefinition, as name or namespace/name, attaching the interface instead of meshnet
+	Network string `json:"network,omitempty"`
+	// Traffic engine data path, either af_packet (default) or sriov
+	DataPath string `json:"data_path,omitempty"`
+	// SR-IOV device plugin resource of the interface for sriov data path
+	Resource string `json:"resource,omitempty"`
+	// PCI address of the interface for sriov data path; defaults to the device allocated by the device plugin,
+	// and is required if the resource is shared with other interfaces of the port pod
+	PCIAddress string `json:"pci_address,omitempty"`
+}
+
+// IxiaTGDPDK defines the hugepages of traffic engines with SR-IOV interfaces
+type IxiaTGDPDK struct {
+	// Hugepage size, either 2Mi or 1Gi (default)
+	HugepageSize string `json:"hugepage_size,omitempty"`
+	// Hugepages per traffic engine, defaults to 2Gi
+	Hugepages string `json:"hugepages,omitempty"`
 }
 
 // IxiaTGIntfStatus defines the mapping between endpoint ports and encasing pods
@@ -49,6 +87,53 @@ type IxiaTGIntfStatus struct {
 type IxiaTGSvcEP struct {
 	PodName     string   `json:"pod_name,omitempty"`
 	ServiceName []string `json:"service_names,omitempty"`
+	Hosts       []string `json:"hosts,omitempty"`
+}
+
+// IxiaTGLicense defines the license servers used by the controller; only one of the options may be set
+type IxiaTGLicense struct {
+	// Secret, in the node namespace, with license server addresses under "addresses"
+	SecretRef string `json:"secret_ref,omitempty"`
+	// License server addresses, as host or host:port
+	Servers []string `json:"servers,omitempty"`
+	// Deploy the license server container of the release alongside the controller
+	Bundled bool `json:"bundled,omitempty"`
+}
+
+// IxiaTGTLS defines the certificate served by the controller and gNMI endpoints; only one of the options may be set
+type IxiaTGTLS struct {
+	// Secret of type kubernetes.io/tls in the node namespace
+	SecretRef string `json:"secret_ref,omitempty"`
+	// Generate a CA and certificate for the node
+	Generate bool `json:"generate,omitempty"`
+}
+
+// IxiaTGNetworkPolicy defines the clients allowed to access the api endpoints of the controller
+type IxiaTGNetworkPolicy struct {
+	// Client address ranges allowed access
+	ClientCIDRs []string `json:"client_cidrs,omitempty"`
+	// Client namespaces allowed access
+	ClientNamespaces []string `json:"client_namespaces,omitempty"`
+}
+
+// IxiaTGContainerStatus defines the observed state of a generated container
+type IxiaTGContainerStatus struct {
+	Name         string `json:"name,omitempty"`
+	Image        string `json:"image,omitempty"`
+	Ready        bool   `json:"ready"`
+	RestartCount int32  `json:"restart_count"`
+	// Running, or the waiting or terminated reason
+	State string `json:"state,omitempty"`
+}
+
+// IxiaTGPodStatus defines the observed state of a generated pod
+type IxiaTGPodStatus struct {
+	Name       string                  `json:"name,omitempty"`
+	Phase      string                  `json:"phase,omitempty"`
+	Node       string                  `json:"node,omitempty"`
+	PodIP      string                  `json:"pod_ip,omitempty"`
+	Restarts   int32                   `json:"restarts"`
+	Containers []IxiaTGContainerStatus `json:"containers,omitempty"`
 }
 
 // IxiaTGInitContainer defines the init container parameters
@@ -57,6 +142,18 @@ type IxiaTGInitContainer struct {
 	Sleep uint32 `json:"sleep,omitempty"`
 }
 
+// IxiaTGSecurity defines the security context of traffic and protocol engine containers
+type IxiaTGSecurity struct {
+	// Security profile, either privileged or unprivileged; defaults to operator setting
+	Profile string `json:"profile,omitempty"`
+	// Capabilities added for unprivileged profile, overrides the default set
+	Capabilities []string `json:"capabilities,omitempty"`
+	// Seccomp profile type, one of RuntimeDefault, Unconfined or Localhost
+	SeccompProfile string `json:"seccomp_profile,omitempty"`
+	// Seccomp profile file for Localhost seccomp profile type
+	SeccompLocalhostProfile string `json:"seccomp_localhost_profile,omitempty"`
+}
+
 // IxiaTGSpec defines the desired state of IxiaTG
 type IxiaTGSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
@@ -72,6 +169,37 @@ type IxiaTGSpec struct {
 	Interfaces []IxiaTGIntf `json:"interfaces,omitempty"`
 	// Init container image of the node
 	InitContainer IxiaTGInitContainer `json:"init_container,omitempty"`
+	// Image pull secrets, in the node namespace, for all generated pods
+	ImagePullSecrets []string `json:"image_pull_secrets,omitempty"`
+	// Image pull policy per component name, as in release config; "*" applies to all components
+	ImagePullPolicy map[string]string `json:"image_pull_policy,omitempty"`
+	// Security context of port pods
+	Security IxiaTGSecurity `json:"security,omitempty"`
+	// Partial pod template strategically merged onto the generated controller pod
+	// +kubebuilder:pruning:PreserveUnknownFields
+	ControllerPodTemplate *runtime.RawExtension `json:"controller_pod_template,omitempty"`
+	// Partial pod template strategically merged onto the generated port pods
+	// +kubebuilder:pruning:PreserveUnknownFields
+	PortPodTemplate *runtime.RawExtension `json:"port_pod_template,omitempty"`
+	// Deadline for all pods to be ready, after which the node is marked failed; overrides operator default
+	DeployTimeout *metav1.Duration `json:"deploy_timeout,omitempty"`
+	// Update policy of generated objects drifted from spec, release configmap or license secret, either none (default)
+	// or auto; with none existing objects are never updated or recreated, only missing ones are created
+	UpdatePolicy string `json:"update_policy,omitempty"`
+	// License servers of the controller; defaults to the operator license secret or release configmap
+	License *IxiaTGLicense `json:"license,omitempty"`
+	// License pool the node takes a seat from; defaults to the "default" pool, if configured in operator
+	LicensePool string `json:"license_pool,omitempty"`
+	// TLS certificate of the controller and gNMI endpoints; controller serves a self-signed certificate if not set
+	TLS *IxiaTGTLS `json:"tls,omitempty"`
+	// Network policies isolating the generated pods; api endpoints are open to all clients if none are specified
+	NetworkPolicy *IxiaTGNetworkPolicy `json:"network_policy,omitempty"`
+	// IP family policy of generated services, one of SingleStack, PreferDualStack or RequireDualStack
+	IPFamilyPolicy string `json:"ip_family_policy,omitempty"`
+	// IP families of generated services in order of preference, IPv4 and/or IPv6
+	IPFamilies []string `json:"ip_families,omitempty"`
+	// DPDK settings of traffic engines with SR-IOV interfaces
+	DPDK IxiaTGDPDK `json:"dpdk,omitempty"`
 }
 
 // IxiaTGStatus defines the observed state of IxiaTG
@@ -87,6 +215,17 @@ type IxiaTGStatus struct {
 	Interfaces []IxiaTGIntfStatus `json:"interfaces,omitempty"`
 	// List of OTG service names
 	ApiEndPoint IxiaTGSvcEP `json:"api_endpoint,omitempty"`
+	// Observed state of generated controller and port pods
+	Pods []IxiaTGPodStatus `json:"pods,omitempty"`
+	// License pool the node holds a seat in
+	LicenseSeat string `json:"license_seat,omitempty"`
+	// Hash of the spec generation, release data and operator secret versions the generated objects were
+	// last rendered from; a deployed node is rendered again only once it changes
+	RenderHash string `json:"render_hash,omitempty"`
+	// Conditions of the node, e.g. LicenseConfigured or PortPodsAvailable
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -211,7 +211,8 @@
 package v1beta1
 
 import (
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -241,6 +242,36 @@ func (in *IxiaTG) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IxiaTGContainerStatus) DeepCopyInto(out *IxiaTGContainerStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IxiaTGContainerStatus.
+func (in *IxiaTGContainerStatus) DeepCopy() *IxiaTGContainerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IxiaTGContainerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IxiaTGDPDK) DeepCopyInto(out *IxiaTGDPDK) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IxiaTGDPDK.
+func (in *IxiaTGDPDK) DeepCopy() *IxiaTGDPDK {
+	if in == nil {
+		return nil
+	}
+	out := new(IxiaTGDPDK)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IxiaTGInitContainer) DeepCopyInto(out *IxiaTGInitContainer) {
 	*out = *in
@@ -286,6 +317,26 @@ func (in *IxiaTGIntfStatus) DeepCopy() *IxiaTGIntfStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IxiaTGLicense) DeepCopyInto(out *IxiaTGLicense) {
+	*out = *in
+	if in.Servers != nil {
+		in, out := &in.Servers, &out.Servers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IxiaTGLicense.
+func (in *IxiaTGLicense) DeepCopy() *IxiaTGLicense {
+	if in == nil {
+		return nil
+	}
+	out := new(IxiaTGLicense)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IxiaTGList) DeepCopyInto(out *IxiaTGList) {
 	*out = *in
@@ -318,6 +369,71 @@ func (in *IxiaTGList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IxiaTGNetworkPolicy) DeepCopyInto(out *IxiaTGNetworkPolicy) {
+	*out = *in
+	if in.ClientCIDRs != nil {
+		in, out := &in.ClientCIDRs, &out.ClientCIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ClientNamespaces != nil {
+		in, out := &in.ClientNamespaces, &out.ClientNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IxiaTGNetworkPolicy.
+func (in *IxiaTGNetworkPolicy) DeepCopy() *IxiaTGNetworkPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(IxiaTGNetworkPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IxiaTGPodStatus) DeepCopyInto(out *IxiaTGPodStatus) {
+	*out = *in
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = make([]IxiaTGContainerStatus, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IxiaTGPodStatus.
+func (in *IxiaTGPodStatus) DeepCopy() *IxiaTGPodStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IxiaTGPodStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IxiaTGSecurity) DeepCopyInto(out *IxiaTGSecurity) {
+	*out = *in
+	if in.Capabilities != nil {
+		in, out := &in.Capabilities, &out.Capabilities
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IxiaTGSecurity.
+func (in *IxiaTGSecurity) DeepCopy() *IxiaTGSecurity {
+	if in == nil {
+		return nil
+	}
+	out := new(IxiaTGSecurity)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IxiaTGSpec) DeepCopyInto(out *IxiaTGSpec) {
 	*out = *in
@@ -325,7 +441,7 @@ func (in *IxiaTGSpec) DeepCopyInto(out *IxiaTGSpec) {
 		in, out := &in.ApiEndPoint, &out.ApiEndPoint
 		*out = make(map[string]IxiaTGSvcPort, len(*in))
 		for key, val := range *in {
-			(*out)[key] = val
+			(*out)[key] = *val.DeepCopy()
 		}
 	}
 	if in.Interfaces != nil {
@@ -334,6 +450,55 @@ func (in *IxiaTGSpec) DeepCopyInto(out *IxiaTGSpec) {
 		copy(*out, *in)
 	}
 	out.InitContainer = in.InitContainer
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ImagePullPolicy != nil {
+		in, out := &in.ImagePullPolicy, &out.ImagePullPolicy
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.Security.DeepCopyInto(&out.Security)
+	if in.ControllerPodTemplate != nil {
+		in, out := &in.ControllerPodTemplate, &out.ControllerPodTemplate
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PortPodTemplate != nil {
+		in, out := &in.PortPodTemplate, &out.PortPodTemplate
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DeployTimeout != nil {
+		in, out := &in.DeployTimeout, &out.DeployTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.License != nil {
+		in, out := &in.License, &out.License
+		*out = new(IxiaTGLicense)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(IxiaTGTLS)
+		**out = **in
+	}
+	if in.NetworkPolicy != nil {
+		in, out := &in.NetworkPolicy, &out.NetworkPolicy
+		*out = new(IxiaTGNetworkPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IPFamilies != nil {
+		in, out := &in.IPFamilies, &out.IPFamilies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	out.DPDK = in.DPDK
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IxiaTGSpec.
@@ -355,6 +520,20 @@ func (in *IxiaTGStatus) DeepCopyInto(out *IxiaTGStatus) {
 		copy(*out, *in)
 	}
 	in.ApiEndPoint.DeepCopyInto(&out.ApiEndPoint)
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = make([]IxiaTGPodStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IxiaTGStatus.
@@ -375,6 +554,11 @@ func (in *IxiaTGSvcEP) DeepCopyInto(out *IxiaTGSvcEP) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IxiaTGSvcEP.
@@ -387,9 +571,36 @@ func (in *IxiaTGSvcEP) DeepCopy() *IxiaTGSvcEP {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IxiaTGSvcExpose) DeepCopyInto(out *IxiaTGSvcExpose) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IxiaTGSvcExpose.
+func (in *IxiaTGSvcExpose) DeepCopy() *IxiaTGSvcExpose {
+	if in == nil {
+		return nil
+	}
+	out := new(IxiaTGSvcExpose)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IxiaTGSvcPort) DeepCopyInto(out *IxiaTGSvcPort) {
 	*out = *in
+	if in.Expose != nil {
+		in, out := &in.Expose, &out.Expose
+		*out = new(IxiaTGSvcExpose)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IxiaTGSvcPort.
@@ -401,3 +612,18 @@ func (in *IxiaTGSvcPort) DeepCopy() *IxiaTGSvcPort {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IxiaTGTLS) DeepCopyInto(out *IxiaTGTLS) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IxiaTGTLS.
+func (in *IxiaTGTLS) DeepCopy() *IxiaTGTLS {
+	if in == nil {
+		return nil
+	}
+	out := new(IxiaTGTLS)
+	in.DeepCopyInto(out)
+	return out
+}
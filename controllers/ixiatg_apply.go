@@ -0,0 +1,165 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	errapi "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	FIELD_MANAGER string = "keng-operator"
+
+	SPEC_HASH_ANNOTATION   string = "network.keysight.com/spec-hash"
+	RELEASE_ANNOTATION     string = "network.keysight.com/release"
+	CONFIG_HASH_ANNOTATION string = "network.keysight.com/config-hash"
+	SPEC_HASH_LENGTH       int    = 16
+)
+
+// applyObject creates or updates a generated object through server-side apply under the operator field
+// manager; only fields set by the operator are owned, so labels and fields added by others are retained
+func (r *IxiaTGReconciler) applyObject(ctx context.Context, obj client.Object) error {
+	u := &unstructured.Unstructured{}
+	if uObj, ok := obj.(*unstructured.Unstructured); ok {
+		u = uObj.DeepCopy()
+	} else {
+		gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+		if err != nil {
+			return err
+		}
+		data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return err
+		}
+		u.Object = data
+		u.SetGroupVersionKind(gvk)
+	}
+
+	// Drop server populated fields and empty defaults of typed objects
+	unstructured.RemoveNestedField(u.Object, "status")
+	unstructured.RemoveNestedField(u.Object, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(u.Object, "metadata", "resourceVersion")
+	unstructured.RemoveNestedField(u.Object, "metadata", "managedFields")
+	unstructured.RemoveNestedField(u.Object, "spec", "template", "metadata", "creationTimestamp")
+
+	return r.Apply(ctx, client.ApplyConfigurationFromUnstructured(u), client.FieldOwner(FIELD_MANAGER), client.ForceOwnership)
+}
+
+// specHash returns the hash of the rendered object along with the release it is built from
+func specHash(obj client.Object, release string) (string, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write(data)
+	h.Write([]byte(release))
+	return hex.EncodeToString(h.Sum(nil))[:SPEC_HASH_LENGTH], nil
+}
+
+// applyGenerated stamps the rendered object with its spec hash and release, and applies it unless the
+// existing object carries the same hash; pods, being immutable, are deleted on mismatch and recreated by a
+// later reconcile. Existing objects without a hash, e.g. created by earlier operator versions, are adopted:
+// only the hash and release are stamped on them, so upgrading the operator does not recreate them.
+// Without update, only missing objects are created and existing ones are left as they are.
+func (r *IxiaTGReconciler) applyGenerated(ctx context.Context, obj client.Object, release string, update bool) error {
+	hash, err := specHash(obj, release)
+	if err != nil {
+		return err
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[SPEC_HASH_ANNOTATION] = hash
+	annotations[RELEASE_ANNOTATION] = release
+	obj.SetAnnotations(annotations)
+
+	existing := obj.DeepCopyObject().(client.Object)
+	err = r.getGenerated(ctx, client.ObjectKeyFromObject(obj), existing)
+	if err == nil {
+		existingHash := existing.GetAnnotations()[SPEC_HASH_ANNOTATION]
+		if existingHash == hash || !update {
+			return nil
+		}
+		if existingHash == "" {
+			log.Infof("Adopting %v with spec hash %v", obj.GetName(), hash)
+			return r.adoptGenerated(ctx, existing, hash, release)
+		}
+		if _, ok := obj.(*corev1.Pod); ok {
+			if !existing.GetDeletionTimestamp().IsZero() {
+				return nil
+			}
+			log.Infof("Pod %v does not match spec hash %v (found %v), recreating", obj.GetName(), hash, existingHash)
+			return client.IgnoreNotFound(r.Delete(ctx, existing))
+		}
+		log.Infof("Object %v does not match spec hash %v (found %v), updating", obj.GetName(), hash, existingHash)
+	} else if !errapi.IsNotFound(err) {
+		return err
+	}
+	return r.applyObject(ctx, obj)
+}
+
+// adoptGenerated stamps the spec hash and release on an existing object with a metadata-only patch,
+// leaving the fields set by its earlier manager as they are
+func (r *IxiaTGReconciler) adoptGenerated(ctx context.Context, existing client.Object, hash string, release string) error {
+	base := existing.DeepCopyObject().(client.Object)
+	annotations := existing.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[SPEC_HASH_ANNOTATION] = hash
+	annotations[RELEASE_ANNOTATION] = release
+	existing.SetAnnotations(annotations)
+	return client.IgnoreNotFound(r.Patch(ctx, existing, client.MergeFrom(base)))
+}
+
+// getGenerated gets an object from the cache, falling back to the API server for objects the cache does not
+// select, e.g. pods created by earlier operator versions without the labels selecting them into the cache
+func (r *IxiaTGReconciler) getGenerated(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	err := r.Get(ctx, key, obj)
+	if errapi.IsNotFound(err) && r.APIReader != nil {
+		err = r.APIReader.Get(ctx, key, obj)
+	}
+	return err
+}
+
+// deleteGenerated deletes a generated object by name; objects not present, or of kinds not installed in the
+// cluster, are skipped
+func (r *IxiaTGReconciler) deleteGenerated(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	err := r.Delete(ctx, obj, opts...)
+	if err == nil {
+		log.Infof("Deleted %v in %v", obj.GetName(), obj.GetNamespace())
+	} else if errapi.IsNotFound(err) || meta.IsNoMatchError(err) {
+		err = nil
+	} else {
+		log.Errorf("Failed to delete %v in %v - %v", obj.GetName(), obj.GetNamespace(), err)
+	}
+	return err
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	errapi "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestApplyGenerated(t *testing.T) {
+	meta := func(name string, annotations map[string]string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: "ixia-c", Annotations: annotations}
+	}
+	cfgMap := func(annotations map[string]string, data string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: meta(CTRL_CFG_MAP_NAME, annotations), Data: map[string]string{CTRL_MAP_FILE_NAME: data}}
+	}
+	pod := func(annotations map[string]string, image string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: meta("otg-port-eth1", annotations), Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "te", Image: image}}}}
+	}
+	tests := []struct {
+		name     string
+		existing client.Object
+		obj      client.Object
+		// uncached simulates an existing object outside the narrowed cache
+		uncached    bool
+		wantDeleted bool
+		// wantData is the configmap data, or the pod image, after apply
+		wantData string
+	}{
+		{"create configmap", nil, cfgMap(nil, "new"), false, false, "new"},
+		{"configmap without hash", cfgMap(nil, "old"), cfgMap(nil, "new"), false, false, "old"},
+		{"configmap with other hash", cfgMap(map[string]string{SPEC_HASH_ANNOTATION: "0123"}, "old"), cfgMap(nil, "new"), false, false, "new"},
+		{"pod without hash", pod(map[string]string{"keep": "yes"}, "te:1"), pod(nil, "te:2"), false, false, "te:1"},
+		{"pod with other hash", pod(map[string]string{SPEC_HASH_ANNOTATION: "0123"}, "te:1"), pod(nil, "te:2"), false, true, ""},
+		{"uncached pod without hash", pod(nil, "te:1"), pod(nil, "te:2"), true, false, "te:1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := []client.Object{}
+			if tt.existing != nil {
+				objs = append(objs, tt.existing)
+			}
+			r := testReconciler(t, objs...)
+			if tt.uncached {
+				r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+					Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+						return errapi.NewNotFound(corev1.Resource("pods"), key.Name)
+					},
+				})
+			}
+
+			ctx := context.Background()
+			rendered := tt.obj.DeepCopyObject().(client.Object)
+			if err := r.applyGenerated(ctx, tt.obj, "local", true); err != nil {
+				t.Fatalf("applyGenerated() error = %v", err)
+			}
+			got := tt.obj.DeepCopyObject().(client.Object)
+			err := r.APIReader.Get(ctx, client.ObjectKeyFromObject(tt.obj), got)
+			if tt.wantDeleted {
+				if !errapi.IsNotFound(err) {
+					t.Errorf("drifted pod not deleted, get error = %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.GetAnnotations()[SPEC_HASH_ANNOTATION] == "" || got.GetAnnotations()[RELEASE_ANNOTATION] != "local" {
+				t.Errorf("applied object annotations = %v, want spec hash and release", got.GetAnnotations())
+			}
+			if cm, ok := got.(*corev1.ConfigMap); ok && cm.Data[CTRL_MAP_FILE_NAME] != tt.wantData {
+				t.Errorf("applied data = %v, want %v", cm.Data[CTRL_MAP_FILE_NAME], tt.wantData)
+			}
+			if p, ok := got.(*corev1.Pod); ok {
+				// Pods without a hash are adopted as they are
+				if p.Spec.Containers[0].Image != tt.wantData {
+					t.Errorf("adopted pod image = %v, want %v", p.Spec.Containers[0].Image, tt.wantData)
+				}
+				if existing := tt.existing.GetAnnotations(); existing != nil && p.Annotations["keep"] != existing["keep"] {
+					t.Errorf("adopted pod annotations = %v, want existing ones kept", p.Annotations)
+				}
+			}
+
+			// Objects matching their hash are left unchanged
+			version := got.GetResourceVersion()
+			if err = r.applyGenerated(ctx, rendered, "local", true); err != nil {
+				t.Fatalf("applyGenerated() error = %v", err)
+			}
+			if err = r.APIReader.Get(ctx, client.ObjectKeyFromObject(tt.obj), got); err != nil || got.GetResourceVersion() != version {
+				t.Errorf("object matching spec hash updated, version %v -> %v (%v)", version, got.GetResourceVersion(), err)
+			}
+		})
+	}
+}
+
+func TestApplyGeneratedWithoutUpdate(t *testing.T) {
+	drifted := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "otg-port-eth1", Namespace: "ixia-c", Annotations: map[string]string{SPEC_HASH_ANNOTATION: "0123"}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "te", Image: "te:1"}}},
+	}
+	r := testReconciler(t, drifted)
+	ctx := context.Background()
+
+	pod := drifted.DeepCopy()
+	pod.Annotations = nil
+	pod.Spec.Containers[0].Image = "te:2"
+	if err := r.applyGenerated(ctx, pod, "local", false); err != nil {
+		t.Fatalf("applyGenerated() error = %v", err)
+	}
+	got := &corev1.Pod{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(drifted), got); err != nil {
+		t.Fatalf("drifted pod recreated without update - %v", err)
+	}
+	if got.Spec.Containers[0].Image != "te:1" {
+		t.Errorf("drifted pod updated without update, image %v", got.Spec.Containers[0].Image)
+	}
+
+	cfgMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: CTRL_CFG_MAP_NAME, Namespace: "ixia-c"}}
+	if err := r.applyGenerated(ctx, cfgMap, "local", false); err != nil {
+		t.Fatalf("applyGenerated() error = %v", err)
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(cfgMap), &corev1.ConfigMap{}); err != nil {
+		t.Errorf("missing configmap not created without update - %v", err)
+	}
+}
+
+func TestGetGenerated(t *testing.T) {
+	legacy := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "otg-port-eth1", Namespace: "ixia-c"}}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "tls", Namespace: "ixia-c"}}
+	tests := []struct {
+		name      string
+		apiReader bool
+		wantFound bool
+	}{
+		{"api reader fallback", true, true},
+		{"without api reader", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testReconciler(t, legacy, secret)
+			// Neither the pod of an earlier operator version nor the secret is selected into the cache
+			r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+				Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+					return errapi.NewNotFound(corev1.Resource("pods"), key.Name)
+				},
+			})
+			if !tt.apiReader {
+				r.APIReader = nil
+			}
+
+			ctx := context.Background()
+			err := r.getGenerated(ctx, client.ObjectKeyFromObject(legacy), &corev1.Pod{})
+			if found := err == nil; found != tt.wantFound || (err != nil && !errapi.IsNotFound(err)) {
+				t.Errorf("getGenerated() error = %v, want found %v", err, tt.wantFound)
+			}
+			got, err := r.GetSecret(ctx, secret.Name, secret.Namespace)
+			if err != nil || (got != nil) != tt.wantFound {
+				t.Errorf("GetSecret() = %v, %v, want found %v", got, err, tt.wantFound)
+			}
+		})
+	}
+}
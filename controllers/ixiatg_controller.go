@@ -23,13 +23,16 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 	"k8s.io/utils/pointer"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	errapi "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -39,6 +42,9 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
@@ -81,6 +87,12 @@ const (
 	PORT_GROUP_NAME_INFIX string = "-port-group-"
 	CTRL_POD_NAME_SUFFIX  string = "-controller"
 	INIT_CONT_NAME_PREFIX string = "init-"
+	NODE_LABEL            string = "network.keysight.com/ixiatg"
+
+	REPLICATED_FROM_LABEL         string = "secretsync.ixiatg.com/replicated-from"
+	REPLICATED_VERSION_ANNOTATION string = "secretsync.ixiatg.com/replicated-resource-version"
+
+	DEFAULT_CREATE_PARALLELISM int = 16
 
 	CTRL_CFG_MAP_NAME   string = "controller-config"
 	CTRL_MAP_VOL_NAME   string = "config"
@@ -129,18 +141,81 @@ const (
 	MIN_CPU_TRAFFIC    string = "200m"
 	MIN_CPU_CONTROLLER string = "10m"
 	MIN_CPU_GNMI       string = "10m"
+
+	SECURITY_PRIVILEGED   string = "privileged"
+	SECURITY_UNPRIVILEGED string = "unprivileged"
+)
+
+var (
+	// Capabilities granted to traffic and protocol engines in unprivileged security profile
+	defaultCapabilities = []string{"NET_ADMIN", "NET_RAW", "IPC_LOCK", "SYS_NICE", "SYS_RESOURCE"}
 )
 
 var (
+	// Release info cache shared by concurrent reconciles, guarded by relLock
+	relLock       sync.RWMutex
 	componentDep  map[string]topoDep = make(map[string]topoDep)
 	latestVersion string             = ""
 )
 
+func relDep(release string) topoDep {
+	relLock.RLock()
+	defer relLock.RUnlock()
+	return componentDep[release]
+}
+
+func hasRelDep(release string) bool {
+	relLock.RLock()
+	defer relLock.RUnlock()
+	_, ok := componentDep[release]
+	return ok
+}
+
+func setRelDep(release string, dep topoDep) {
+	relLock.Lock()
+	defer relLock.Unlock()
+	componentDep[release] = dep
+}
+
+func getLatestVersion() string {
+	relLock.RLock()
+	defer relLock.RUnlock()
+	return latestVersion
+}
+
+func setLatestVersion(release string) {
+	relLock.Lock()
+	defer relLock.Unlock()
+	latestVersion = release
+}
+
 // IxiaTGReconciler reconciles a IxiaTG object
 type IxiaTGReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+	// Image pull secrets in operator namespace, replicated to node namespaces
+	ImagePullSecrets []string
+	// Additional secrets in operator namespace, e.g. TLS, replicated to node namespaces
+	ReplicatedSecrets []string
+	// Registry rewrite rules applied to all container images
+	RegistryRewrites []RegistryRewrite
+	// Security profile of port pods when not specified in spec
+	DefaultSecurityProfile string
+	// Deadline for pods to be ready when not specified in spec
+	DeployTimeout time.Duration
+	// Restarts of a crash looping container after which the node is marked failed
+	RestartThreshold int32
+	// Maximum number of IxiaTG nodes reconciled concurrently
+	MaxConcurrentReconciles int
+	// Maximum number of port pods created concurrently for a node
+	CreateParallelism int
+	// Run a single license server in the operator namespace shared by all nodes
+	SharedLicenseServer bool
+	// Seats per license pool; nodes exceeding the seats of their pool are queued
+	LicensePools map[string]int
+	// Uncached reader used for license seat accounting and objects outside the narrowed cache
+	APIReader client.Reader
 }
 
 type componentRel struct {
@@ -150,6 +225,7 @@ type componentRel struct {
 	DefArgs         []string
 	DefCmd          []string
 	DefEnv          map[string]string
+	Digest          string                 `json:"digest,omitempty"`
 	Env             map[string]interface{} `json:"env"`
 	LiveNessEnable  *bool                  `json:"liveness-enable,omitempty"`
 	LiveNessDelay   int32                  `json:"liveness-initial-delay,omitempty"`
@@ -159,8 +235,10 @@ type componentRel struct {
 	Name            string                 `json:"name"`
 	Path            string                 `json:"path"`
 	Port            int32
-	StartUpEnable   *bool  `json:"startup-enable,omitempty"`
-	Tag             string `json:"tag"`
+	Probes          map[string]probeRel `json:"probes,omitempty"`
+	PullPolicy      string              `json:"pull-policy,omitempty"`
+	StartUpEnable   *bool               `json:"startup-enable,omitempty"`
+	Tag             string              `json:"tag"`
 	VolMntName      string
 	VolMntPath      string
 }
@@ -206,10 +284,15 @@ type location struct {
 //+kubebuilder:rbac:groups=network.keysight.com,resources=ixiatgs,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=network.keysight.com,resources=ixiatgs/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=network.keysight.com,resources=ixiatgs/finalizers,verbs=update
-//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;create;delete
-//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;delete
-//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;delete
-//+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;create;patch;delete
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;patch;delete
+//+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;patch;delete
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;patch;delete
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;patch;delete
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes;grpcroutes;backendtlspolicies,verbs=get;list;watch;create;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -256,7 +339,7 @@ func (r *IxiaTGReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	} else {
 		if containsString(ixia.GetFinalizers(), myFinalizerName) {
 			// Delete secrets, if copied
-			if err = r.DeleteSecrets(ctx, ixia.Namespace); err != nil {
+			if err = r.DeleteSecrets(ctx, ixia); err != nil {
 				return ctrl.Result{}, err
 			}
 			for _, intf := range ixia.Status.Interfaces {
@@ -270,6 +353,10 @@ func (r *IxiaTGReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 				log.Errorf("Failed to delete controller pod in %v, err %v", ixia.Namespace, err)
 				return ctrl.Result{}, err
 			}
+			if err = r.releaseSharedLicenseServer(ctx, ixia); err != nil {
+				log.Errorf("Failed to release shared license server - %v", err)
+				return ctrl.Result{}, err
+			}
 
 			controllerutil.RemoveFinalizer(ixia, myFinalizerName)
 			if err = r.Update(ctx, ixia); err != nil {
@@ -286,6 +373,9 @@ func (r *IxiaTGReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	otgCtrlName := ixia.Name + CTRL_POD_NAME_SUFFIX
 	log.Infof("Desired State: %v, Current State: %v", ixia.Spec.DesiredState, ixia.Status.State)
 	if ixia.Spec.DesiredState == ixia.Status.State {
+		if ixia.Status.State == STATE_DEPLOYED {
+			return r.reconcileDeployed(ctx, req, ixia)
+		}
 		return ctrl.Result{}, nil
 	} else if ixia.Spec.DesiredState == STATE_INITED {
 		otgCtrl, err := r.deployController(ctx, nil, ixia, true)
@@ -307,6 +397,32 @@ func (r *IxiaTGReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 				err = errors.New(fmt.Sprintf("Unsupported interface %s for Controller version; interface must be eth1", ixia.Spec.Interfaces[0].Name))
 			} else if !otgCtrl && ixia.Name == CONTROLLER_NAME {
 				err = errors.New(fmt.Sprintf("Node name %s is reserved for Controller pod, use some other name", CONTROLLER_NAME))
+			} else if err = validateApiEndPoints(ixia); err != nil {
+				log.Errorf("Invalid api endpoint configuration - %v", err)
+			} else if err = validatePullPolicies(ixia); err != nil {
+				log.Errorf("Invalid image pull policy configuration - %v", err)
+			} else if err = validateSecurity(ixia); err != nil {
+				log.Errorf("Invalid security configuration - %v", err)
+			} else if err = validatePodTemplates(ixia); err != nil {
+				log.Errorf("Invalid pod template configuration - %v", err)
+			} else if err = validateDeployTimeout(ixia); err != nil {
+				log.Errorf("Invalid deploy timeout configuration - %v", err)
+			} else if err = validateUpdatePolicy(ixia); err != nil {
+				log.Errorf("Invalid update policy configuration - %v", err)
+			} else if err = validateLicense(ixia); err != nil {
+				log.Errorf("Invalid license configuration - %v", err)
+			} else if err = r.validateLicensePool(ixia); err != nil {
+				log.Errorf("Invalid license pool configuration - %v", err)
+			} else if err = validateTLS(ixia); err != nil {
+				log.Errorf("Invalid TLS configuration - %v", err)
+			} else if err = validateNetworkPolicy(ixia); err != nil {
+				log.Errorf("Invalid network policy configuration - %v", err)
+			} else if err = validateIPFamilies(ixia); err != nil {
+				log.Errorf("Invalid IP family configuration - %v", err)
+			} else if err = validateNetworks(ixia); err != nil {
+				log.Errorf("Invalid interface network configuration - %v", err)
+			} else if err = validateDataPaths(ixia); err != nil {
+				log.Errorf("Invalid interface data path configuration - %v", err)
 			} else {
 				if !otgCtrl && len(crdList.Items) > 1 {
 					specVer := crdList.Items[0].Spec.Release
@@ -334,6 +450,14 @@ func (r *IxiaTGReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 							}
 							podName = ixia.Name + PORT_GROUP_NAME_INFIX + intf.Group
 						}
+						if intf.Network != "" && !otgCtrl {
+							err = errors.New(fmt.Sprintf("Network, in config, is not supported for version older than %s", IXIA_C_OTG_VERSION))
+							break
+						}
+						if intf.DataPath == DATA_PATH_SRIOV && !otgCtrl {
+							err = errors.New(fmt.Sprintf("Data path %s, in config, is not supported for version older than %s", DATA_PATH_SRIOV, IXIA_C_OTG_VERSION))
+							break
+						}
 						genPodNames = append(genPodNames,
 							networkv1beta1.IxiaTGIntfStatus{PodName: podName, Name: intf.Name, Intf: deployIntf})
 					}
@@ -350,6 +474,9 @@ func (r *IxiaTGReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 							svcList = append(svcList, "service-"+podName)
 						}
 						genSvcEP := networkv1beta1.IxiaTGSvcEP{PodName: podName, ServiceName: svcList}
+						if otgCtrl {
+							genSvcEP.Hosts = exposedHosts(ixia)
+						}
 						log.Infof("Node update with interfaces: %v", genPodNames)
 						ixia.Status.Interfaces = genPodNames
 						ixia.Status.State = ixia.Spec.DesiredState
@@ -364,11 +491,13 @@ func (r *IxiaTGReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 			ixia.Status.Reason = err.Error()
 			ixia.Status.State = STATE_FAILED
 		}
+		// License seat is taken again on deployment
+		ixia.Status.LicenseSeat = ""
 
 		err = r.Status().Update(ctx, ixia)
 		if err != nil {
 			log.Errorf("Failed to update ixia status - %v", err)
-			return ctrl.Result{RequeueAfter: time.Second}, err
+			return ctrl.Result{}, err
 		}
 
 		return ctrl.Result{}, nil
@@ -381,101 +510,155 @@ func (r *IxiaTGReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		err = r.Status().Update(ctx, ixia)
 		if err != nil {
 			log.Errorf("Failed to update ixia status - %v", err)
-			return ctrl.Result{RequeueAfter: time.Second}, err
+			return ctrl.Result{}, err
 		}
 
 		return ctrl.Result{}, nil
 	}
 
+	// Nodes exceeding the seats of their license pool wait until a seat is freed
+	if reason, err := r.admitLicenseSeat(ctx, ixia); err != nil {
+		log.Errorf("Failed to take license seat for %v - %v", ixia.Name, err)
+		return ctrl.Result{}, err
+	} else if reason != "" {
+		log.Infof("Node %v queued - %s", ixia.Name, reason)
+		if ixia.Status.State != STATE_QUEUED || ixia.Status.Reason != reason || ixia.Status.LicenseSeat != "" {
+			ixia.Status.State = STATE_QUEUED
+			ixia.Status.Reason = reason
+			ixia.Status.LicenseSeat = ""
+			if err = r.Status().Update(ctx, ixia); err != nil {
+				log.Errorf("Failed to update ixia status - %v", err)
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
 	// Check if we need to create resources or not
-	err = r.ReconcileSecrets(ctx, req, ixia)
+	if err = r.ReconcileSecrets(ctx, req, ixia); err != nil {
+		log.Errorf("Failed to sync secrets of %v in %v - %v", ixia.Name, ixia.Namespace, err)
+		return ctrl.Result{}, err
+	}
 
 	requeue := false
+	requeueAfter := r.deployTimeout(ixia)
+	statusChanged := false
 	found := &corev1.Pod{}
+	ctrlDeploy := &appsv1.Deployment{}
 	otgCtrl, err := r.deployController(ctx, nil, ixia, true)
+	if err == nil {
+		// License servers in spec are resolved before deploying; the failure is terminal until spec changes
+		statusChanged, err = r.reconcileLicense(ctx, ixia)
+		if err != nil {
+			log.Errorf("Invalid license configuration - %v", err)
+			ixia.Status.State = STATE_FAILED
+			ixia.Status.Reason = err.Error()
+			if err = r.Status().Update(ctx, ixia); err != nil {
+				log.Errorf("Failed to update ixia status - %v", err)
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+	}
 	if err == nil {
 		if !otgCtrl {
 			otgCtrlName = ixia.Name
+			err = r.getGenerated(ctx, types.NamespacedName{Name: otgCtrlName, Namespace: ixia.Namespace}, found)
+		} else {
+			err = r.Get(ctx, types.NamespacedName{Name: otgCtrlName, Namespace: ixia.Namespace}, ctrlDeploy)
 		}
-		err = r.Get(ctx, types.NamespacedName{Name: otgCtrlName, Namespace: ixia.Namespace}, found)
 	}
 	if err != nil && errapi.IsNotFound(err) {
 		// need to deploy, but first deploy controller if not present
-		podMap := make(map[string][]string)
-		for _, intf := range ixia.Status.Interfaces {
-			if _, ok := podMap[intf.PodName]; ok {
-				podMap[intf.PodName] = append(podMap[intf.PodName], intf.Intf)
-			} else {
-				podMap[intf.PodName] = []string{intf.Intf}
-			}
-		}
-		log.Infof("Deployment interface map created: %v", podMap)
-		if _, err = r.deployController(ctx, &podMap, ixia, false); err == nil {
-			log.Infof("Successfully deployed controller pod")
-			for name, intfs := range podMap {
-				log.Infof("Creating pod %v", name)
-				if err = r.podForIxia(ctx, name, intfs, ixia); err != nil {
-					log.Infof("Pod %v create failed!", name)
-					break
-				}
-				log.Infof("Pod %v created!", name)
-			}
-		}
-
-		if err == nil {
-			log.Infof("All pods created!")
+		if err = r.deployNode(ctx, ixia); err == nil {
 			requeue = true
-		} else {
-			log.Errorf("Failed to create pod for %v in %v - %v", ixia.Name, ixia.Namespace, err)
 		}
 	} else if err != nil {
-		// Log but don't update status
+		// Don't update status, retry with backoff
 		log.Error(err, "Failed to get pod")
-		requeue = true
-		err = nil
+		return ctrl.Result{}, err
 	} else {
-		if found.Status.Phase == corev1.PodFailed {
-			err = errors.New(fmt.Sprintf("Pod %s failed - %s", found.Name, found.Status.Reason))
-		} else if err == nil {
-			var contStatus []corev1.ContainerStatus
-			if found.Status.Phase != corev1.PodRunning {
-				requeue = true
+		// Re-apply to resume a partial deployment and to recreate objects drifted from their spec;
+		// objects matching their spec hash are left unchanged
+		err = r.deployNode(ctx, ixia)
+		ctrlPods := []corev1.Pod{}
+		deployStart := found.CreationTimestamp
+		if !otgCtrl {
+			ctrlPods = append(ctrlPods, *found)
+		} else {
+			deployStart = ctrlDeploy.CreationTimestamp
+		}
+		if otgCtrl && err == nil {
+			// Controller readiness is tracked through its Deployment rollout
+			var rolledOut bool
+			if rolledOut, err = deploymentRolledOut(ctrlDeploy); err == nil {
+				if !rolledOut {
+					log.Infof("Controller deployment %s rollout in progress", ctrlDeploy.Name)
+					requeue = true
+				}
+				ctrlPods, err = r.getControllerPods(ctx, ctrlDeploy)
 			}
-			for _, s := range found.Status.ContainerStatuses {
-				contStatus = append(contStatus, s)
+		}
+		// Deploy and rollout failures are retained; pods are only checked for failure without them
+		for i := 0; err == nil && i < len(ctrlPods); i++ {
+			err = podFailure(&ctrlPods[i], r.restartThreshold())
+		}
+		deployedPods := append([]corev1.Pod{}, ctrlPods...)
+		missing := false
+		if err == nil {
+			var contStatus []corev1.ContainerStatus
+			for _, p := range ctrlPods {
+				if p.Status.Phase != corev1.PodRunning {
+					requeue = true
+				}
+				for _, s := range p.Status.ContainerStatuses {
+					contStatus = append(contStatus, s)
+				}
 			}
 			for _, podEntry := range ixia.Status.Interfaces {
-				err = r.Get(ctx, types.NamespacedName{Name: podEntry.PodName, Namespace: ixia.Namespace}, found)
-				if err != nil {
+				err = r.getGenerated(ctx, types.NamespacedName{Name: podEntry.PodName, Namespace: ixia.Namespace}, found)
+				if (err != nil && errapi.IsNotFound(err)) || (err == nil && !found.DeletionTimestamp.IsZero()) {
+					// Being recreated
+					missing = true
+					err = nil
+					continue
+				} else if err != nil {
 					break
 				}
-				if found.Status.Phase == corev1.PodFailed {
-					err = errors.New(fmt.Sprintf("Pod %s failed - %s", found.Name, found.Status.Reason))
+				if err = podFailure(found, r.restartThreshold()); err != nil {
 					break
 				}
 				if found.Status.Phase != corev1.PodRunning {
 					requeue = true
 				}
+				deployedPods = append(deployedPods, *found)
 				for _, s := range found.Status.ContainerStatuses {
 					contStatus = append(contStatus, s)
 				}
 			}
 
+			if missing {
+				log.Infof("Port pods of %v being recreated", ixia.Name)
+				requeue = true
+			}
 			if err == nil {
 				for _, c := range contStatus {
-					if c.State.Waiting != nil && c.State.Waiting.Reason == "ErrImagePull" {
-						msg := c.State.Waiting.Message
-						if strings.Contains(msg, "repository does not exist") || strings.Contains(msg, "access to the resource is denied") {
-							err = errors.New(fmt.Sprintf("Container %s failed - %s", c.Name, c.State.Waiting.Message))
-							break
-						}
-					}
 					if !c.Ready {
 						requeue = true
 					}
 				}
 			}
 		}
+		for _, p := range deployedPods {
+			if deployStart.Before(&p.CreationTimestamp) {
+				deployStart = p.CreationTimestamp
+			}
+		}
+		if err == nil && requeue && !missing {
+			err = deployTimeoutError(r.deployTimeout(ixia), deployStart, deployedPods)
+			requeueAfter = time.Until(deployStart.Add(r.deployTimeout(ixia)))
+		}
+		statusChanged = updatePodsStatus(ixia, deployedPods) || statusChanged
 	}
 
 	if !requeue || err != nil {
@@ -486,8 +669,17 @@ func (r *IxiaTGReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 			requeue = false
 		} else {
 			ixia.Status.State = STATE_DEPLOYED
+			// Deployed nodes are rendered again only once the hash changes, see reconcileDeployed
+			if hash, hashErr := r.renderHash(ctx, ixia); hashErr == nil {
+				ixia.Status.RenderHash = hash
+			} else {
+				log.Errorf("Failed to get render hash of %v - %v", ixia.Name, hashErr)
+			}
 		}
+		statusChanged = true
+	}
 
+	if statusChanged {
 		err = r.Status().Update(ctx, ixia)
 		if err != nil {
 			log.Errorf("Failed to update ixia status - %v", err)
@@ -495,10 +687,15 @@ func (r *IxiaTGReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		}
 	}
 
-	if requeue {
-		return ctrl.Result{RequeueAfter: time.Second}, err
+	if err != nil {
+		// Retried with rate limited backoff
+		return ctrl.Result{}, err
+	}
+	if requeue && requeueAfter > 0 {
+		// Progress is driven by pod and deployment events; requeue at the deadline to report a timeout
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
 	}
-	return ctrl.Result{}, err
+	return ctrl.Result{}, nil
 }
 
 func (r *IxiaTGReconciler) getRelInfo(ctx context.Context, release string, namespace string) error {
@@ -557,7 +754,7 @@ func (r *IxiaTGReconciler) getRelInfo(ctx context.Context, release string, names
 	}
 
 	if len(data) == 0 {
-		if _, ok := componentDep[release]; !ok {
+		if !hasRelDep(release) {
 			log.Infof("Version specific information could not be located; ensure a valid version is used")
 			log.Infof("Also ensure the version specific ConfigMap yaml is applied if working in offline mode")
 			return errors.New(fmt.Sprintf("Dependency info for version %s could not be located; ensure configmap with that version is loaded", release))
@@ -663,6 +860,10 @@ func (r *IxiaTGReconciler) loadRelInfo(ctx context.Context, release string, relD
 			}
 
 			// For all components update health check parameters
+			if err = validateProbes(compRef); err != nil {
+				log.Error(err)
+				return err
+			}
 			if compRef.LiveNessDelay == 0 {
 				compRef.LiveNessDelay = LIVENESS_DELAY
 			}
@@ -708,10 +909,10 @@ func (r *IxiaTGReconciler) loadRelInfo(ctx context.Context, release string, relD
 			}
 		}
 
-		componentDep[relEntry.Release] = topoEntry
+		setRelDep(relEntry.Release, topoEntry)
 		if release == DEFAULT_VERSION {
-			latestVersion = relEntry.Release
-			release = latestVersion
+			setLatestVersion(relEntry.Release)
+			release = relEntry.Release
 		}
 		log.Infof("Found version info for %s through %s", relEntry.Release, source)
 		log.Infof("Mapped controller components:")
@@ -724,7 +925,7 @@ func (r *IxiaTGReconciler) loadRelInfo(ctx context.Context, release string, relD
 		}
 	}
 
-	if _, ok := componentDep[release]; !ok {
+	if !hasRelDep(release) {
 		log.Errorf("Release %s related dependency could not be located", release)
 		return errors.New(fmt.Sprintf("Dependency info for version %s could not be located; ensure configmap with that version is loaded", release))
 	}
@@ -733,13 +934,10 @@ func (r *IxiaTGReconciler) loadRelInfo(ctx context.Context, release string, relD
 }
 
 func (r *IxiaTGReconciler) deleteIxiaPod(ctx context.Context, name string, ixia *networkv1beta1.IxiaTG) error {
-	found := &corev1.Pod{}
-	if r.Get(ctx, types.NamespacedName{Name: name, Namespace: ixia.Namespace}, found) == nil {
-		if err := r.Delete(ctx, found, client.GracePeriodSeconds(5)); err != nil {
-			log.Errorf("Failed to delete ixia pod %v - %v", found, err)
-			return err
-		}
-		log.Infof("Deleted ixia pod %v", found)
+	// Pods are deleted by name, as pods of earlier operator versions lack the label of cached pods
+	found := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ixia.Namespace}}
+	if err := r.deleteGenerated(ctx, found, client.GracePeriodSeconds(5)); err != nil {
+		return err
 	}
 
 	// Now delete the services
@@ -756,24 +954,27 @@ func (r *IxiaTGReconciler) deleteIxiaPod(ctx context.Context, name string, ixia
 }
 
 func (r *IxiaTGReconciler) deleteController(ctx context.Context, ixia *networkv1beta1.IxiaTG) error {
-	found := &corev1.Pod{}
-	ctrlPodName := ixia.Name + "-controller"
-	if r.Get(ctx, types.NamespacedName{Name: ctrlPodName, Namespace: ixia.Namespace}, found) == nil {
-		if err := r.Delete(ctx, found, client.GracePeriodSeconds(5)); err != nil {
-			log.Errorf("Failed to delete associated controller %v - %v", found, err)
-			return err
-		}
-		log.Infof("Deleted controller %v", found)
+	ctrlPodName := ixia.Name + CTRL_POD_NAME_SUFFIX
+	found := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: ctrlPodName, Namespace: ixia.Namespace}}
+	if err := r.deleteGenerated(ctx, found, client.GracePeriodSeconds(5)); err != nil {
+		return err
+	}
+	if err := r.deleteControllerDeployment(ctx, ixia); err != nil {
+		return err
 	}
 
 	// Now delete the config map
-	ctrlCfgMap := &corev1.ConfigMap{}
-	if r.Get(ctx, types.NamespacedName{Name: CTRL_CFG_MAP_NAME, Namespace: ixia.Namespace}, ctrlCfgMap) == nil {
-		if err := r.Delete(ctx, ctrlCfgMap, client.GracePeriodSeconds(0)); err != nil {
-			log.Errorf("Failed to delete config map %v - %v", ctrlCfgMap, err)
-			return err
-		}
-		log.Infof("Deleted config map %v", ctrlCfgMap)
+	ctrlCfgMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: CTRL_CFG_MAP_NAME, Namespace: ixia.Namespace}}
+	if err := r.deleteGenerated(ctx, ctrlCfgMap, client.GracePeriodSeconds(0)); err != nil {
+		return err
+	}
+
+	// Now delete the ingress and routes, if any
+	if err := r.unexposeController(ctx, ixia); err != nil {
+		return err
+	}
+	if err := r.deleteNetworkPolicies(ctx, ixia); err != nil {
+		return err
 	}
 
 	// Now delete the services
@@ -807,24 +1008,24 @@ func (r *IxiaTGReconciler) deployController(ctx context.Context, podMap *map[str
 		log.Infof("No ixiatg version specified, using default version %s", depVersion)
 	}
 
-	if _, ok := componentDep[depVersion]; !ok || depVersion == DEFAULT_VERSION || componentDep[depVersion].Source == DS_CONFIGMAP {
+	if !hasRelDep(depVersion) || depVersion == DEFAULT_VERSION || relDep(depVersion).Source == DS_CONFIGMAP {
 		if err = r.getRelInfo(ctx, depVersion, ixia.Namespace); err != nil {
 			log.Errorf("Failed to get release information for %s", depVersion)
 			return isOtgCtrl, err
 		}
 	}
 	if depVersion == DEFAULT_VERSION {
-		if latestVersion == "" {
+		if getLatestVersion() == "" {
 			log.Errorf("Failed to get release information for %s", depVersion)
 			return isOtgCtrl, errors.New(fmt.Sprintf("Failed to get release information for version %s", DEFAULT_VERSION))
 		} else {
-			depVersion = latestVersion
+			depVersion = getLatestVersion()
 		}
 	}
 
 	// Determine if Controller supports new OTG model
 	found := false
-	for _, comp := range componentDep[depVersion].Controller.Containers {
+	for _, comp := range relDep(depVersion).Controller.Containers {
 		if comp.ContainerName == CONTROLLER_NAME {
 			found = true
 			isOtgCtrl, err = versionLaterOrEqual(IXIA_C_OTG_VERSION, comp.Tag)
@@ -836,7 +1037,11 @@ func (r *IxiaTGReconciler) deployController(ctx context.Context, podMap *map[str
 	}
 	if checkOtgOnly {
 		return isOtgCtrl, nil
-	} else if !isOtgCtrl {
+	}
+	if err = r.applyPortServices(ctx, *podMap, ixia, depVersion); err != nil {
+		return isOtgCtrl, err
+	}
+	if !isOtgCtrl {
 		// Skip if already deployed for old KNE configs
 		if err = r.List(ctx, podList, opts...); err != nil {
 			log.Errorf("Failed to list current pods %v", err)
@@ -850,6 +1055,13 @@ func (r *IxiaTGReconciler) deployController(ctx context.Context, podMap *map[str
 		}
 	}
 
+	if isOtgCtrl {
+		if err = r.reconcileTLS(ctx, ixia); err != nil {
+			log.Errorf("Failed to set up TLS of %v - %v", ixia.Name, err)
+			return isOtgCtrl, err
+		}
+	}
+
 	// Deploy controller and services
 	containers, err := r.containersForController(ctx, ixia, depVersion, isOtgCtrl)
 	if err != nil {
@@ -857,19 +1069,21 @@ func (r *IxiaTGReconciler) deployController(ctx context.Context, podMap *map[str
 	}
 
 	locations := []location{}
-	svcSuffix := ixia.Namespace + SERVICE_NAME_SUFFIX
-	pePort := ":" + strconv.Itoa(int(PROTOCOL_ENG_PORT))
-	tePort := ":" + strconv.Itoa(int(TRAFFIC_ENG_PORT))
 	for podName, intfs := range *podMap {
-		podSvc := "service-" + podName + "." + svcSuffix
+		podSvc := r.serviceHost(ctx, "service-"+podName, ixia.Namespace)
+		teLoc := hostPort(podSvc, TRAFFIC_ENG_PORT)
+		peLoc := hostPort(podSvc, PROTOCOL_ENG_PORT)
 		for index, intf := range intfs {
-			svcLoc := podSvc + tePort + "+" + podSvc + pePort
+			svcLoc := teLoc + "+" + peLoc
 			if len(intfs) > 1 {
-				svcLoc = podSvc + tePort + ";" + strconv.Itoa(index+1) + "+" + podSvc + pePort
+				svcLoc = teLoc + ";" + strconv.Itoa(index+1) + "+" + peLoc
 			}
 			locations = append(locations, location{Location: intf, EndPoint: svcLoc})
 		}
 	}
+	sort.Slice(locations, func(i, j int) bool {
+		return locations[i].Location < locations[j].Location
+	})
 	mappings := controllerMap{LocationMap: locations}
 	log.Infof("Prepared the location map object: %v", mappings)
 
@@ -884,15 +1098,16 @@ func (r *IxiaTGReconciler) deployController(ctx context.Context, podMap *map[str
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      CTRL_CFG_MAP_NAME,
 			Namespace: ixia.Namespace,
+			Labels:    map[string]string{NODE_LABEL: ixia.Name},
 		},
 		Data: intfMap,
 	}
-	err = r.Create(ctx, ctrlCfgMap)
+	err = r.applyGenerated(ctx, ctrlCfgMap, depVersion, autoUpdate(ixia))
 	if err != nil {
-		log.Errorf("Failed to create config map controller-config in %v, err %v", ixia.Namespace, err)
+		log.Errorf("Failed to apply config map controller-config in %v, err %v", ixia.Namespace, err)
 		return isOtgCtrl, err
 	}
-	log.Infof("Created the controller location mappings: %v", ctrlCfgMap)
+	log.Infof("Applied the controller location mappings: %v", ctrlCfgMap)
 
 	localObjRef := corev1.LocalObjectReference{Name: CTRL_CFG_MAP_NAME}
 	cfgMapVolSrc := &corev1.ConfigMapVolumeSource{LocalObjectReference: localObjRef}
@@ -905,32 +1120,65 @@ func (r *IxiaTGReconciler) deployController(ctx context.Context, podMap *map[str
 			Name:      otgCtrlName,
 			Namespace: ixia.Namespace,
 			Labels: map[string]string{
-				"app": otgCtrlName,
+				"app":      otgCtrlName,
+				NODE_LABEL: ixia.Name,
 			},
 		},
 		Spec: corev1.PodSpec{
 			Containers:                    containers,
 			TerminationGracePeriodSeconds: pointer.Int64(TERMINATION_TIMEOUT_SEC),
+			ImagePullSecrets:              r.imagePullSecrets(ixia),
 		},
 	}
 	if isOtgCtrl {
 		pod.Spec.Volumes = []corev1.Volume{volume}
+		if ixia.Spec.TLS != nil {
+			pod.Spec.Volumes = append(pod.Spec.Volumes, tlsVolume(ixia))
+		}
+		if err = applyPodTemplate(pod, ixia.Spec.ControllerPodTemplate, ""); err != nil {
+			return isOtgCtrl, err
+		}
 	} else {
 		pod.ObjectMeta.Name = CONTROLLER_NAME
 	}
-	log.Infof("Creating controller pod %v", pod)
-	err = r.Create(ctx, pod)
-	if err != nil {
-		log.Errorf("Failed to create pod %v in %v, err %v", pod.Name, pod.Namespace, err)
-		return isOtgCtrl, err
+	if isOtgCtrl {
+		// Roll the controller when its location mappings change
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		pod.Annotations[CONFIG_HASH_ANNOTATION] = ctrlCfgMap.Annotations[SPEC_HASH_ANNOTATION]
+		// and when its certificate is renewed
+		if ixia.Spec.TLS != nil {
+			if pod.Annotations[TLS_HASH_ANNOTATION], err = r.tlsHash(ctx, ixia); err != nil {
+				return isOtgCtrl, err
+			}
+		}
+		if err = r.createControllerDeployment(ctx, pod, ixia, depVersion); err != nil {
+			return isOtgCtrl, err
+		}
+	} else {
+		log.Infof("Applying controller pod %v", pod)
+		err = r.applyGenerated(ctx, pod, depVersion, autoUpdate(ixia))
+		if err != nil {
+			log.Errorf("Failed to apply pod %v in %v, err %v", pod.Name, pod.Namespace, err)
+			return isOtgCtrl, err
+		}
 	}
 
 	// Now create and map services
 	services := r.getControllerService(ixia, isOtgCtrl)
 	for _, s := range services {
-		err = r.Create(ctx, &s)
+		err = r.applyService(ctx, &s, depVersion, autoUpdate(ixia))
 		if err != nil {
-			log.Errorf("Failed to create service %v in %v, err %v", s, ixia.Namespace, err)
+			log.Errorf("Failed to apply service %v in %v, err %v", s, ixia.Namespace, err)
+			return isOtgCtrl, err
+		}
+	}
+	if isOtgCtrl {
+		if err = r.exposeController(ctx, ixia, depVersion); err != nil {
+			return isOtgCtrl, err
+		}
+		if err = r.reconcileNetworkPolicies(ctx, ixia, depVersion); err != nil {
 			return isOtgCtrl, err
 		}
 	}
@@ -938,26 +1186,88 @@ func (r *IxiaTGReconciler) deployController(ctx context.Context, podMap *map[str
 	return isOtgCtrl, nil
 }
 
+// deployNode applies the controller and port pods of the IxiaTG along with their services
+func (r *IxiaTGReconciler) deployNode(ctx context.Context, ixia *networkv1beta1.IxiaTG) error {
+	podMap := make(map[string][]string)
+	for _, intf := range ixia.Status.Interfaces {
+		if _, ok := podMap[intf.PodName]; ok {
+			podMap[intf.PodName] = append(podMap[intf.PodName], intf.Intf)
+		} else {
+			podMap[intf.PodName] = []string{intf.Intf}
+		}
+	}
+	log.Infof("Deployment interface map created: %v", podMap)
+	_, err := r.deployController(ctx, &podMap, ixia, false)
+	if err == nil {
+		log.Infof("Successfully deployed controller pod")
+		err = r.createPortPods(ctx, podMap, ixia)
+	}
+
+	if err == nil {
+		log.Infof("All pods created!")
+	} else {
+		log.Errorf("Failed to create pod for %v in %v - %v", ixia.Name, ixia.Namespace, err)
+	}
+	return err
+}
+
+// createPortPods creates the port pods concurrently, bounded by the create parallelism;
+// errors of all failed pods are aggregated
+func (r *IxiaTGReconciler) createPortPods(ctx context.Context, podMap map[string][]string, ixia *networkv1beta1.IxiaTG) error {
+	parallelism := r.CreateParallelism
+	if parallelism <= 0 {
+		parallelism = DEFAULT_CREATE_PARALLELISM
+	}
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []string
+	for name, intfs := range podMap {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(name string, intfs []string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			log.Infof("Creating pod %v", name)
+			if err := r.podForIxia(ctx, name, intfs, ixia); err != nil {
+				log.Infof("Pod %v create failed!", name)
+				mu.Lock()
+				errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+				mu.Unlock()
+				return
+			}
+			log.Infof("Pod %v created!", name)
+		}(name, intfs)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return errors.New(fmt.Sprintf("Failed to create %d of %d pods - %s", len(errs), len(podMap), strings.Join(errs, "; ")))
+	}
+	return nil
+}
+
 func (r *IxiaTGReconciler) podForIxia(ctx context.Context, podName string, intfList []string, ixia *networkv1beta1.IxiaTG) error {
 	initContainers := []corev1.Container{}
-	versionToDeploy := latestVersion
+	versionToDeploy := getLatestVersion()
 	if ixia.Spec.Release != "" && ixia.Spec.Release != DEFAULT_VERSION {
 		versionToDeploy = ixia.Spec.Release
 	}
-	contPodMap := componentDep[versionToDeploy].Ixia.Containers
-	args := []string{strconv.Itoa(len(intfList) + 1), "10"}
-	initImage := "networkop/init-wait:latest"
+	contPodMap := relDep(versionToDeploy).Ixia.Containers
+	args := []string{strconv.Itoa(netdevCount(ixia, intfList) + 1), "10"}
+	initImage := DEFAULT_INIT_IMAGE
 	initContainerMsg := "Added default init container"
 	if ixia.Spec.InitContainer.Image == "" {
 		for _, cont := range contPodMap {
 			if strings.HasPrefix(cont.Name, INIT_CONT_NAME_PREFIX) {
 				initContainerMsg = "Added custom init container from configmap"
 				initCont := corev1.Container{
-					Name:            cont.ContainerName,
-					Image:           cont.Path + ":" + cont.Tag,
-					ImagePullPolicy: "IfNotPresent",
+					Name:                     cont.ContainerName,
+					Image:                    r.imageName(cont.Path, cont.Tag, cont.Digest),
+					ImagePullPolicy:          pullPolicy(ixia, cont),
+					TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
 				}
-				updateControllerContainer(&initCont, cont, false)
+				updateControllerContainer(&initCont, cont, false, false)
 				// Since the args are dynamic based on topology deployment, we verify if args
 				// are applied based on configmap spec; otherwise apply default args
 				if len(initCont.Args) == 0 {
@@ -974,12 +1284,17 @@ func (r *IxiaTGReconciler) podForIxia(ctx context.Context, podName string, intfL
 		}
 		initImage = ixia.Spec.InitContainer.Image
 	}
-	if len(initContainers) == 0 {
+	sortContainers(initContainers)
+	// Multus attaches its interfaces before containers start, so the default init container only waits
+	// for interfaces wired by meshnet
+	networks := multusNetworks(ixia, intfList)
+	if len(initContainers) == 0 && (ixia.Spec.InitContainer.Image != "" || len(networks) < len(intfList)) {
 		defaultInitCont := corev1.Container{
-			Name:            "init-container",
-			Image:           initImage,
-			Args:            args,
-			ImagePullPolicy: "IfNotPresent",
+			Name:                     "init-container",
+			Image:                    r.imageName(initImage, "", ""),
+			Args:                     args,
+			ImagePullPolicy:          pullPolicy(ixia, componentRel{Name: "init-container"}),
+			TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
 		}
 		initContainers = append(initContainers, defaultInitCont)
 	}
@@ -989,22 +1304,32 @@ func (r *IxiaTGReconciler) podForIxia(ctx context.Context, podName string, intfL
 			Name:      podName,
 			Namespace: ixia.Namespace,
 			Labels: map[string]string{
-				"app":  podName,
-				"topo": ixia.Namespace,
+				"app":      podName,
+				"topo":     ixia.Namespace,
+				NODE_LABEL: ixia.Name,
 			},
 		},
 		Spec: corev1.PodSpec{
 			InitContainers:                initContainers,
 			Containers:                    r.containersForIxia(podName, intfList, ixia),
 			TerminationGracePeriodSeconds: pointer.Int64(TERMINATION_TIMEOUT_SEC),
+			ImagePullSecrets:              r.imagePullSecrets(ixia),
 		},
 	}
-	err := r.Create(ctx, pod)
-	if err != nil {
+	if err := setNetworksAnnotation(pod, networks); err != nil {
+		return err
+	}
+	if len(sriovInterfaces(ixia, intfList)) > 0 {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, hugepagesVolume(ixia))
+	}
+	if err := applyPodTemplate(pod, ixia.Spec.PortPodTemplate, podName+"-"); err != nil {
 		return err
 	}
+	return r.applyGenerated(ctx, pod, versionToDeploy, autoUpdate(ixia))
+}
 
-	// Now create corresponding services
+// portService returns the service of the traffic and protocol engines of a port pod
+func portService(podName string, ixia *networkv1beta1.IxiaTG) *corev1.Service {
 	svcPorts := []corev1.ServicePort{}
 	portName := "port-" + strconv.Itoa(int(TRAFFIC_ENG_PORT))
 	svcPorts = append(svcPorts, corev1.ServicePort{Name: portName, Port: TRAFFIC_ENG_PORT, TargetPort: intstr.IntOrString{IntVal: TRAFFIC_ENG_PORT}})
@@ -1023,11 +1348,24 @@ func (r *IxiaTGReconciler) podForIxia(ctx context.Context, podName string, intfL
 			Type:  "LoadBalancer",
 		},
 	}
-	err = r.Create(ctx, service)
-	if err != nil {
-		return err
-	}
+	setIPFamilies(service, ixia)
+	return service
+}
 
+// applyPortServices applies the services of the port pods; they are applied ahead of the controller, whose
+// locations refer to their cluster IPs
+func (r *IxiaTGReconciler) applyPortServices(ctx context.Context, podMap map[string][]string, ixia *networkv1beta1.IxiaTG, release string) error {
+	podNames := []string{}
+	for podName := range podMap {
+		podNames = append(podNames, podName)
+	}
+	sort.Strings(podNames)
+	for _, podName := range podNames {
+		if err := r.applyService(ctx, portService(podName, ixia), release, autoUpdate(ixia)); err != nil {
+			log.Errorf("Failed to apply service of pod %v in %v, err %v", podName, ixia.Namespace, err)
+			return err
+		}
+	}
 	return nil
 }
 
@@ -1039,6 +1377,12 @@ func (r *IxiaTGReconciler) getControllerService(ixia *networkv1beta1.IxiaTG, isO
 	if isOtgCtrl {
 		for name, svc := range ixia.Spec.ApiEndPoint {
 			contPort := corev1.ServicePort{Name: name, Port: svc.In, TargetPort: intstr.IntOrString{IntVal: svc.In}}
+			// Endpoints exposed through Ingress or Gateway share the external IP of the
+			// ingress controller or gateway, so cluster internal service suffices
+			svcType := corev1.ServiceTypeLoadBalancer
+			if exposeType(svc) != EXPOSE_LOAD_BALANCER {
+				svcType = corev1.ServiceTypeClusterIP
+			}
 			service := corev1.Service{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "service-" + name + "-" + ctrlPodName,
@@ -1049,7 +1393,7 @@ func (r *IxiaTGReconciler) getControllerService(ixia *networkv1beta1.IxiaTG, isO
 						"app": ctrlPodName,
 					},
 					Ports: []corev1.ServicePort{contPort},
-					Type:  "LoadBalancer",
+					Type:  svcType,
 				},
 			}
 			services = append(services, service)
@@ -1102,10 +1446,13 @@ func (r *IxiaTGReconciler) getControllerService(ixia *networkv1beta1.IxiaTG, isO
 		})
 	}
 
+	for index := range services {
+		setIPFamilies(&services[index], ixia)
+	}
 	return services
 }
 
-func updateControllerContainer(cont *corev1.Container, pubRel componentRel, newGNMI bool) {
+func updateControllerContainer(cont *corev1.Container, pubRel componentRel, newGNMI bool, tls bool) {
 	conEnvs := []corev1.EnvVar{}
 	cfgMapEnv := make(map[string]string)
 	for ek, ev := range pubRel.DefEnv {
@@ -1121,6 +1468,9 @@ func updateControllerContainer(cont *corev1.Container, pubRel componentRel, newG
 			Value: value,
 		})
 	}
+	sort.Slice(conEnvs, func(i, j int) bool {
+		return conEnvs[i].Name < conEnvs[j].Name
+	})
 
 	if len(pubRel.Args) > 0 {
 		cont.Args = pubRel.Args
@@ -1139,6 +1489,9 @@ func updateControllerContainer(cont *corev1.Container, pubRel componentRel, newG
 	if len(conEnvs) > 0 {
 		cont.Env = conEnvs
 	}
+	if tls && (cont.Name == CONTROLLER_NAME || cont.Name == GNMI_NAME) {
+		setTLSArgs(cont)
+	}
 }
 
 func versionLaterOrEqual(baseVer string, chkVer string) (bool, error) {
@@ -1164,47 +1517,62 @@ func (r *IxiaTGReconciler) containersForController(ctx context.Context, ixia *ne
 	lic_found := false
 	lic_container := corev1.Container{}
 	var lic_server_image, lic_server_secret bool
+	var specLicAddr string
+	var specLicBundled bool
+	ports := controllerPorts{http: CTRL_HTTPS_PORT, grpc: CTRL_GRPC_PORT, gnmi: CTRL_GNMI_PORT}
+	if otg {
+		ports = endpointPorts(ixia)
+	}
 
-	if _, ok := componentDep[release].Controller.Containers[IMAGE_CONTROLLER]; !ok {
+	if _, ok := relDep(release).Controller.Containers[IMAGE_CONTROLLER]; !ok {
 		return nil, fmt.Errorf("Failed to find controller entry in configmap for release %s", release)
 	}
-	if _, ok := componentDep[release].Controller.Containers[IMAGE_GNMI_SERVER]; !ok {
+	if _, ok := relDep(release).Controller.Containers[IMAGE_GNMI_SERVER]; !ok {
 		return nil, fmt.Errorf("Failed to find gNMI entry in configmap for release %s", release)
 	}
-	if ctrl, ok := componentDep[release].Controller.Containers[IMAGE_CONTROLLER]; ok {
+	if ctrl, ok := relDep(release).Controller.Containers[IMAGE_CONTROLLER]; ok {
 		noGRPC, err := versionLaterOrEqual(IXIA_C_GRPC_VERSION, ctrl.Tag)
 		if err != nil {
 			log.Error(err)
 		}
 		if !noGRPC {
-			if _, ok := componentDep[release].Controller.Containers[IMAGE_GRPC_SERVER]; !ok {
+			if _, ok := relDep(release).Controller.Containers[IMAGE_GRPC_SERVER]; !ok {
 				return nil, fmt.Errorf("Failed to find gRPC entry in configmap for release %s", release)
 			}
 		}
 	}
-	if _, ok := componentDep[release].Controller.Containers[IMAGE_LICENSE_SERVER]; ok {
+	if _, ok := relDep(release).Controller.Containers[IMAGE_LICENSE_SERVER]; ok {
 		lic_server_image = true
 	}
-	if _, ok := componentDep[release].Controller.Containers[IMAGE_LICENSE_SECRET]; ok {
+	if _, ok := relDep(release).Controller.Containers[IMAGE_LICENSE_SECRET]; ok {
 		lic_server_secret = true
 	}
-	for key, comp := range componentDep[release].Controller.Containers {
-		var pbHdlr *corev1.ProbeHandler = nil
+	if ixia.Spec.License != nil {
+		if specLicAddr, specLicBundled, _, err = r.specLicense(ctx, ixia, release); err != nil {
+			return nil, err
+		}
+	}
+	// Nodes without license servers of their own use the shared license server, if enabled
+	sharedLic := r.SharedLicenseServer && ixia.Spec.License == nil && otg
+	defaultLicAddr := LICENSE_BUNDLED_ADDRESS
+	if sharedLic {
+		defaultLicAddr = sharedLicenseAddress()
+	}
+	for key, comp := range relDep(release).Controller.Containers {
+		var probePort int32
 		if key == IMAGE_LICENSE_SERVER && lic_server_secret {
 			// Secrets based image takes precedence
 			continue
 		}
 		name := comp.ContainerName
-		image := comp.Path
-		if comp.Tag != "" {
-			image += ":" + comp.Tag
-		}
+		image := r.imageName(comp.Path, comp.Tag, comp.Digest)
 		log.Infof("Deploying %s version %s for config version %s, ns %s (source %s)",
-			name, comp.Tag, release, ixia.Namespace, componentDep[release].Source)
+			name, comp.Tag, release, ixia.Namespace, relDep(release).Source)
 		container := corev1.Container{
-			Name:            name,
-			Image:           image,
-			ImagePullPolicy: "IfNotPresent",
+			Name:                     name,
+			Image:                    image,
+			ImagePullPolicy:          pullPolicy(ixia, comp),
+			TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
 		}
 		if comp.VolMntName != "" && otg {
 			volMount := corev1.VolumeMount{Name: comp.VolMntName, ReadOnly: true, MountPath: comp.VolMntPath}
@@ -1224,8 +1592,7 @@ func (r *IxiaTGReconciler) containersForController(ctx context.Context, ixia *ne
 			resRequest["memory"] = resource.MustParse(r)
 		}
 		if name == GNMI_NAME {
-			tcpSock := corev1.TCPSocketAction{Port: intstr.IntOrString{IntVal: CTRL_GNMI_PORT}}
-			pbHdlr = &corev1.ProbeHandler{TCPSocket: &tcpSock}
+			probePort = ports.gnmi
 			newGNMI, err = versionLaterOrEqual(GNMI_NEW_BASE_VERSION, comp.Tag)
 			if err != nil {
 				log.Error(err)
@@ -1237,8 +1604,7 @@ func (r *IxiaTGReconciler) containersForController(ctx context.Context, ixia *ne
 				resRequest["memory"] = resource.MustParse(MIN_MEM_GNMI)
 			}
 		} else if name == CONTROLLER_NAME {
-			tcpSock := corev1.TCPSocketAction{Port: intstr.IntOrString{IntVal: CTRL_GRPC_PORT}}
-			pbHdlr = &corev1.ProbeHandler{TCPSocket: &tcpSock}
+			probePort = ports.grpc
 			if _, ok := resRequest["cpu"]; !ok {
 				resRequest["cpu"] = resource.MustParse(MIN_CPU_CONTROLLER)
 			}
@@ -1246,33 +1612,19 @@ func (r *IxiaTGReconciler) containersForController(ctx context.Context, ixia *ne
 				resRequest["memory"] = resource.MustParse(MIN_MEM_CONTROLLER)
 			}
 		} else if name == LICENSE_NAME {
-			tcpSock := corev1.TCPSocketAction{Port: intstr.IntOrString{IntVal: CTRL_LICENSE_PORT}}
-			pbHdlr = &corev1.ProbeHandler{TCPSocket: &tcpSock}
+			probePort = CTRL_LICENSE_PORT
 		}
 		container.Resources.Requests = resRequest
+		setProbes(&container, comp, probePort)
 
-		if pbHdlr != nil && (comp.LiveNessEnable == nil || *comp.LiveNessEnable) {
-			probe := corev1.Probe{
-				ProbeHandler:                  *pbHdlr,
-				InitialDelaySeconds:           comp.LiveNessDelay,
-				PeriodSeconds:                 comp.LiveNessPeriod,
-				FailureThreshold:              comp.LiveNessFailure,
-				TerminationGracePeriodSeconds: pointer.Int64(1),
-			}
-			container.LivenessProbe = &probe
-		}
-		if pbHdlr != nil && (comp.StartUpEnable == nil || *comp.StartUpEnable) {
-			probe := corev1.Probe{
-				ProbeHandler:     *pbHdlr,
-				PeriodSeconds:    STARTUP_PERIOD,
-				FailureThreshold: STARTUP_FAILURE,
-			}
-			container.StartupProbe = &probe
-		}
-
-		updateControllerContainer(&container, comp, newGNMI)
+		updateControllerContainer(&container, comp, newGNMI, otg && ixia.Spec.TLS != nil)
+		setEndpointPorts(&container, ports, newGNMI)
 		// License server related handling
-		if name == CONTROLLER_NAME {
+		if name == CONTROLLER_NAME && ixia.Spec.License != nil {
+			// License servers in spec take precedence over secret and configmap
+			setEnv(&container, LIC_ENV_VAR, specLicAddr)
+			lic_found = !specLicBundled
+		} else if name == CONTROLLER_NAME {
 			// First check is corresponding secret is present
 			licAddr := ""
 			if secret, err := r.GetSecret(ctx, LIC_SERVER_SECRET, ixia.Namespace); err != nil {
@@ -1301,7 +1653,7 @@ func (r *IxiaTGReconciler) containersForController(ctx context.Context, ixia *ne
 			if !entry_found && lic_found {
 				envEntries = append(envEntries, corev1.EnvVar{Name: LIC_ENV_VAR, Value: licAddr})
 			} else if !lic_found && (lic_server_image || lic_server_secret) {
-				envEntries = append(envEntries, corev1.EnvVar{Name: LIC_ENV_VAR, Value: "localhost"})
+				envEntries = append(envEntries, corev1.EnvVar{Name: LIC_ENV_VAR, Value: defaultLicAddr})
 			}
 			container.Env = envEntries
 		}
@@ -1315,7 +1667,13 @@ func (r *IxiaTGReconciler) containersForController(ctx context.Context, ixia *ne
 			containers = append(containers, container)
 		}
 	}
-	if !lic_found {
+	if !lic_found && sharedLic {
+		if lic_server_image || lic_server_secret {
+			if err = r.applySharedLicenseServer(ctx, release); err != nil {
+				return nil, err
+			}
+		}
+	} else if !lic_found {
 		// Add license server only if secret is present or image is configmap driven
 		if lic_server_image || lic_server_secret {
 			log.Infof("Adding to pod: %s, container: %s, Image: %s, Args: %v, Cmd: %v, Port: %v, Vol: %v",
@@ -1324,40 +1682,45 @@ func (r *IxiaTGReconciler) containersForController(ctx context.Context, ixia *ne
 			containers = append(containers, lic_container)
 		}
 	}
+	if !sharedLic {
+		// A shared license server left unused, e.g. by an operator restarted without it, is removed
+		if err = r.releaseSharedLicenseServer(ctx, nil); err != nil {
+			return nil, err
+		}
+	}
 
+	// Render in a stable order so applied pods remain unchanged across reconciles
+	sortContainers(containers)
 	log.Infof("Done containersForController total containers %v!", len(containers))
 	return containers, nil
 }
 
 func (r *IxiaTGReconciler) containersForIxia(podName string, intfList []string, ixia *networkv1beta1.IxiaTG) []corev1.Container {
 	log.Infof("Get containers for Ixia: %s", podName)
-	argIntfList := ""
-	for _, intf := range intfList {
-		argIntfList += "virtual@af_packet," + intf + " "
-	}
-	argIntfList = argIntfList[:len(argIntfList)-1]
+	argIntfList := trafficIntfList(ixia, intfList)
+	sriovIntfs := sriovInterfaces(ixia, intfList)
 	var containers []corev1.Container
 
-	conSecurityCtx := getDefaultSecurityContext()
-	versionToDeploy := latestVersion
+	conSecurityCtx := r.getSecurityContext(ixia)
+	versionToDeploy := getLatestVersion()
 	if ixia.Spec.Release != "" && ixia.Spec.Release != DEFAULT_VERSION {
 		versionToDeploy = ixia.Spec.Release
 	}
-	for cName, comp := range componentDep[versionToDeploy].Ixia.Containers {
-		var tcpSock corev1.TCPSocketAction
-		var pbHdlr *corev1.ProbeHandler = nil
+	for cName, comp := range relDep(versionToDeploy).Ixia.Containers {
+		var probePort int32
 		if strings.HasPrefix(comp.Name, INIT_CONT_NAME_PREFIX) {
 			continue
 		}
 		log.Infof("Deploying %s version %s for config version %s, ns %s (source %s)",
-			cName, comp.Tag, versionToDeploy, ixia.Namespace, componentDep[versionToDeploy].Source)
+			cName, comp.Tag, versionToDeploy, ixia.Namespace, relDep(versionToDeploy).Source)
 		name := podName + "-" + comp.ContainerName
-		image := comp.Path + ":" + comp.Tag
+		image := r.imageName(comp.Path, comp.Tag, comp.Digest)
 		container := corev1.Container{
-			Name:            name,
-			Image:           image,
-			ImagePullPolicy: "IfNotPresent",
-			SecurityContext: conSecurityCtx,
+			Name:                     name,
+			Image:                    image,
+			ImagePullPolicy:          pullPolicy(ixia, comp),
+			TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+			SecurityContext:          conSecurityCtx,
 		}
 		compCopy := comp
 		compCopy.DefEnv = make(map[string]string)
@@ -1373,8 +1736,7 @@ func (r *IxiaTGReconciler) containersForIxia(podName string, intfList []string,
 		}
 		if cName == IMAGE_PROTOCOL_ENG {
 			compCopy.DefEnv["INTF_LIST"] = strings.Join(intfList, ",")
-			tcpSock = corev1.TCPSocketAction{Port: intstr.IntOrString{IntVal: PROTOCOL_ENG_PORT}}
-			pbHdlr = &corev1.ProbeHandler{TCPSocket: &tcpSock}
+			probePort = PROTOCOL_ENG_PORT
 			if _, ok := resRequest["cpu"]; !ok {
 				resRequest["cpu"] = resource.MustParse(MIN_CPU_PROTOCOL)
 			}
@@ -1387,8 +1749,11 @@ func (r *IxiaTGReconciler) containersForIxia(podName string, intfList []string,
 			}
 		} else {
 			compCopy.DefEnv["ARG_IFACE_LIST"] = argIntfList
-			tcpSock = corev1.TCPSocketAction{Port: intstr.IntOrString{IntVal: TRAFFIC_ENG_PORT}}
-			pbHdlr = &corev1.ProbeHandler{TCPSocket: &tcpSock}
+			if len(sriovIntfs) > 0 {
+				// DPDK drives SR-IOV interfaces from hugepages, see setSRIOVResources
+				delete(compCopy.DefEnv, OPT_NO_HUGEPAGES)
+			}
+			probePort = TRAFFIC_ENG_PORT
 			if _, ok := resRequest["cpu"]; !ok {
 				resRequest["cpu"] = resource.MustParse(MIN_CPU_TRAFFIC)
 			}
@@ -1398,31 +1763,17 @@ func (r *IxiaTGReconciler) containersForIxia(podName string, intfList []string,
 			}
 		}
 		container.Resources.Requests = resRequest
-
-		if pbHdlr != nil && (compCopy.LiveNessEnable == nil || *compCopy.LiveNessEnable) {
-			probe := corev1.Probe{
-				ProbeHandler:                  *pbHdlr,
-				InitialDelaySeconds:           compCopy.LiveNessDelay,
-				PeriodSeconds:                 compCopy.LiveNessPeriod,
-				FailureThreshold:              compCopy.LiveNessFailure,
-				TerminationGracePeriodSeconds: pointer.Int64(1),
-			}
-			container.LivenessProbe = &probe
-		}
-		if pbHdlr != nil && (compCopy.StartUpEnable == nil || *compCopy.StartUpEnable) {
-			probe := corev1.Probe{
-				ProbeHandler:     *pbHdlr,
-				PeriodSeconds:    STARTUP_PERIOD,
-				FailureThreshold: STARTUP_FAILURE,
-			}
-			container.StartupProbe = &probe
+		if cName != IMAGE_PROTOCOL_ENG && len(sriovIntfs) > 0 {
+			setSRIOVResources(&container, ixia, sriovIntfs)
 		}
-		updateControllerContainer(&container, compCopy, false)
+		setProbes(&container, compCopy, probePort)
+		updateControllerContainer(&container, compCopy, false, false)
 		log.Infof("Adding to pod: %s, container: %s, Image: %s, Args: %v, Cmd: %v, Env: %v",
 			podName, name, image, container.Args, container.Command, container.Env)
 		containers = append(containers, container)
 	}
 
+	sortContainers(containers)
 	log.Infof("Done containersForIxia() total containers %v!", len(containers))
 	return containers
 }
@@ -1431,9 +1782,42 @@ func (r *IxiaTGReconciler) containersForIxia(podName string, intfList []string,
 func (r *IxiaTGReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&networkv1beta1.IxiaTG{}).
+		Owns(&appsv1.Deployment{}).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(nodeForPod)).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.nodesForReleaseConfig)).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.nodesForOperatorSecret)).
+		Watches(&networkv1beta1.IxiaTG{}, handler.EnqueueRequestsFromMapFunc(r.queuedNodes)).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
 		Complete(r)
 }
 
+// nodeForPod maps a generated pod to its IxiaTG, so pod state changes trigger a reconcile
+func nodeForPod(ctx context.Context, obj client.Object) []reconcile.Request {
+	name, ok := obj.GetLabels()[NODE_LABEL]
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: obj.GetNamespace()}}}
+}
+
+// sortContainers orders containers by name
+func sortContainers(containers []corev1.Container) {
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].Name < containers[j].Name
+	})
+}
+
+// setEnv sets the value of an environment variable of container, adding it if not present
+func setEnv(container *corev1.Container, name string, value string) {
+	for index, env := range container.Env {
+		if env.Name == name {
+			container.Env[index].Value = value
+			return
+		}
+	}
+	container.Env = append(container.Env, corev1.EnvVar{Name: name, Value: value})
+}
+
 // Helper functions to check and remove string from a slice of strings.
 func containsString(slice []string, s string) bool {
 	for _, item := range slice {
@@ -1444,6 +1828,16 @@ func containsString(slice []string, s string) bool {
 	return false
 }
 
+func removeString(slice []string, s string) (result []string) {
+	for _, item := range slice {
+		if item == s {
+			continue
+		}
+		result = append(result, item)
+	}
+	return
+}
+
 func getDefaultSecurityContext() *corev1.SecurityContext {
 	var sc *corev1.SecurityContext = new(corev1.SecurityContext)
 	t := true
@@ -1452,9 +1846,69 @@ func getDefaultSecurityContext() *corev1.SecurityContext {
 	return sc
 }
 
+func validateSecurity(ixia *networkv1beta1.IxiaTG) error {
+	sec := ixia.Spec.Security
+	switch sec.Profile {
+	case "", SECURITY_PRIVILEGED, SECURITY_UNPRIVILEGED:
+	default:
+		return errors.New(fmt.Sprintf("Unsupported security profile %s; expected %s or %s", sec.Profile, SECURITY_PRIVILEGED, SECURITY_UNPRIVILEGED))
+	}
+	switch corev1.SeccompProfileType(sec.SeccompProfile) {
+	case "", corev1.SeccompProfileTypeRuntimeDefault, corev1.SeccompProfileTypeUnconfined:
+	case corev1.SeccompProfileTypeLocalhost:
+		if sec.SeccompLocalhostProfile == "" {
+			return errors.New(fmt.Sprintf("Seccomp localhost profile file is required for %s seccomp profile", sec.SeccompProfile))
+		}
+	default:
+		return errors.New(fmt.Sprintf("Unsupported seccomp profile %s", sec.SeccompProfile))
+	}
+	return nil
+}
+
+// getSecurityContext returns the security context for traffic and protocol engine containers
+// as per the selected security profile; privileged profile is retained as the operator default
+func (r *IxiaTGReconciler) getSecurityContext(ixia *networkv1beta1.IxiaTG) *corev1.SecurityContext {
+	sec := ixia.Spec.Security
+	profile := sec.Profile
+	if profile == "" {
+		profile = r.DefaultSecurityProfile
+	}
+
+	var sc *corev1.SecurityContext
+	if profile == SECURITY_UNPRIVILEGED {
+		capList := sec.Capabilities
+		if len(capList) == 0 {
+			capList = defaultCapabilities
+		}
+		caps := []corev1.Capability{}
+		for _, c := range capList {
+			caps = append(caps, corev1.Capability(strings.TrimPrefix(strings.ToUpper(c), "CAP_")))
+		}
+		sc = &corev1.SecurityContext{
+			Privileged:               pointer.Bool(false),
+			AllowPrivilegeEscalation: pointer.Bool(false),
+			Capabilities:             &corev1.Capabilities{Add: caps},
+			SeccompProfile:           &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+		}
+	} else {
+		sc = getDefaultSecurityContext()
+	}
+
+	if sec.SeccompProfile != "" {
+		sc.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileType(sec.SeccompProfile)}
+		if sc.SeccompProfile.Type == corev1.SeccompProfileTypeLocalhost {
+			sc.SeccompProfile.LocalhostProfile = pointer.String(sec.SeccompLocalhostProfile)
+		}
+	}
+	log.Infof("Using %s security profile for %s", profile, ixia.Name)
+	return sc
+}
+
+// GetSecret returns the secret, or nil if not found; secrets outside the operator namespace and not
+// replicated by it, e.g. referenced in spec, are not cached and read from the API server
 func (r *IxiaTGReconciler) GetSecret(ctx context.Context, name string, namespace string) (*corev1.Secret, error) {
 	instance := &corev1.Secret{}
-	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, instance)
+	err := r.getGenerated(ctx, types.NamespacedName{Name: name, Namespace: namespace}, instance)
 	if err == nil {
 		return instance, nil
 	} else if errapi.IsNotFound(err) {
@@ -1463,25 +1917,47 @@ func (r *IxiaTGReconciler) GetSecret(ctx context.Context, name string, namespace
 	return nil, err
 }
 
-func (r *IxiaTGReconciler) DeleteSecrets(ctx context.Context, namespace string) error {
-	secretList := []string{LIC_SERVER_SECRET}
-	for _, name := range secretList {
-		instance := &corev1.Secret{}
-		if r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, instance) == nil {
-			if err := r.Delete(ctx, instance); err != nil {
-				log.Errorf("Failed to delete secret %v - %v", instance, err)
-				return err
-			}
-			log.Infof("Deleted secret %v", instance)
+// DeleteSecrets deletes the secrets replicated into the namespace of the node once no other node in the
+// namespace uses them; secrets not replicated by the operator are left untouched
+func (r *IxiaTGReconciler) DeleteSecrets(ctx context.Context, ixia *networkv1beta1.IxiaTG) error {
+	nodes := &networkv1beta1.IxiaTGList{}
+	if err := r.List(ctx, nodes, client.InNamespace(ixia.Namespace)); err != nil {
+		log.Errorf("Failed to get list of IxiaTG nodes - %v", err)
+		return err
+	}
+	for _, node := range nodes.Items {
+		if node.Name != ixia.Name && node.DeletionTimestamp.IsZero() {
+			log.Infof("Secrets in %v retained for %v", ixia.Namespace, node.Name)
+			return nil
 		}
 	}
+
+	secretList := &corev1.SecretList{}
+	opts := []client.ListOption{
+		client.InNamespace(ixia.Namespace),
+		client.HasLabels{REPLICATED_FROM_LABEL},
+	}
+	if err := r.List(ctx, secretList, opts...); err != nil {
+		log.Errorf("Failed to list secrets in %v - %v", ixia.Namespace, err)
+		return err
+	}
+	for index, instance := range secretList.Items {
+		if !strings.HasPrefix(instance.Labels[REPLICATED_FROM_LABEL], CURRENT_NAMESPACE+".") {
+			continue
+		}
+		if err := r.Delete(ctx, &secretList.Items[index]); err != nil && !errapi.IsNotFound(err) {
+			log.Errorf("Failed to delete secret %v - %v", instance.Name, err)
+			return err
+		}
+		log.Infof("Deleted secret %v", instance.Name)
+	}
 	return nil
 }
 
 func (r *IxiaTGReconciler) ReconcileSecrets(ctx context.Context,
 	req ctrl.Request, ixia *networkv1beta1.IxiaTG) error {
 	_ = r.Log.WithValues("ixiatg", req.NamespacedName)
-	secretList := []string{LIC_SERVER_SECRET}
+	secretList := r.replicatedSecrets()
 	// Fetch the Secret instance
 	instance := &corev1.Secret{}
 
@@ -1508,31 +1984,33 @@ func (r *IxiaTGReconciler) ReconcileSecrets(ctx context.Context,
 			return err
 		}
 		secret := &corev1.Secret{}
-		err = r.Get(ctx, types.NamespacedName{Name: targetSecret.Name, Namespace: targetSecret.Namespace}, secret)
-		if err != nil && errapi.IsNotFound(err) {
-			log.Info(fmt.Sprintf("Target secret %s doesn't exist, creating it in namespace %s", targetSecret.Name, targetSecret.Namespace))
-			err = r.Create(ctx, targetSecret)
-			if err != nil {
-				return err
-			}
-		} else {
-			log.Info(fmt.Sprintf("Target secret %s exists, updating it now in namespace %s", targetSecret.Name, targetSecret.Namespace))
-			err = r.Update(ctx, targetSecret)
-			if err != nil {
-				return err
+		// Secrets created by users are not cached
+		err = r.getGenerated(ctx, types.NamespacedName{Name: targetSecret.Name, Namespace: targetSecret.Namespace}, secret)
+		if err == nil {
+			if _, ok := secret.Labels[REPLICATED_FROM_LABEL]; !ok {
+				// Secrets created by users in the topology namespace take precedence
+				log.Infof("Secret %s in namespace %s not replicated by operator, retained", secret.Name, secret.Namespace)
+				continue
+			} else if secret.Annotations[REPLICATED_VERSION_ANNOTATION] == instance.ResourceVersion {
+				continue
 			}
 		}
+		log.Info(fmt.Sprintf("Applying target secret %s in namespace %s", targetSecret.Name, targetSecret.Namespace))
+		err = r.applyObject(ctx, targetSecret)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
 func createSecret(secret *corev1.Secret, name string, namespace string) (*corev1.Secret, error) {
 	labels := map[string]string{
-		"secretsync.ixiatg.com/replicated-from": fmt.Sprintf("%s.%s", secret.Namespace, secret.Name),
+		REPLICATED_FROM_LABEL: fmt.Sprintf("%s.%s", secret.Namespace, secret.Name),
 	}
 	annotations := map[string]string{
-		"secretsync.ixiatg.com/replicated-time":             time.Now().Format("Mon Jan 2 15:04:05 MST 2006"),
-		"secretsync.ixiatg.com/replicated-resource-version": secret.ResourceVersion,
+		"secretsync.ixiatg.com/replicated-time": time.Now().Format("Mon Jan 2 15:04:05 MST 2006"),
+		REPLICATED_VERSION_ANNOTATION:           secret.ResourceVersion,
 	}
 	return &corev1.Secret{
 		TypeMeta: metav1.TypeMeta{
@@ -0,0 +1,357 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	errapi "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	networkv1beta1 "github.com/open-traffic-generator/keng-operator/api/v1beta1"
+)
+
+// testReconciler returns a reconciler backed by a fake client holding objs
+func testReconciler(t *testing.T, objs ...client.Object) *IxiaTGReconciler {
+	t.Helper()
+	return testReconcilerWithFuncs(t, interceptor.Funcs{}, objs...)
+}
+
+// testReconcilerWithFuncs returns a reconciler backed by a fake client holding objs, with calls
+// intercepted by funcs, e.g. to inject errors
+func testReconcilerWithFuncs(t *testing.T, funcs interceptor.Funcs, objs ...client.Object) *IxiaTGReconciler {
+	t.Helper()
+	s := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := networkv1beta1.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(s).WithObjects(objs...).WithStatusSubresource(&networkv1beta1.IxiaTG{}).WithInterceptorFuncs(funcs).Build()
+	return &IxiaTGReconciler{Client: c, Scheme: s, APIReader: c}
+}
+
+// testNode returns an IxiaTG with the given interfaces
+func testNode(name string, intfs ...string) *networkv1beta1.IxiaTG {
+	ixia := &networkv1beta1.IxiaTG{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ixia-c", UID: types.UID("uid-" + name)},
+	}
+	for _, intf := range intfs {
+		ixia.Spec.Interfaces = append(ixia.Spec.Interfaces, networkv1beta1.IxiaTGIntf{Name: intf})
+	}
+	return ixia
+}
+
+// testRelease loads the components of release into the release cache, as if fetched from the release server
+func testRelease(t *testing.T, r *IxiaTGReconciler, release string) {
+	t.Helper()
+	data := []byte(`{"release": "` + release + `", "images": [
+		{"name": "controller", "path": "ghcr.io/open-traffic-generator/keng-controller", "tag": "1.13.0-1"},
+		{"name": "gnmi-server", "path": "ghcr.io/open-traffic-generator/otg-gnmi-server", "tag": "1.14.14"},
+		{"name": "traffic-engine", "path": "ghcr.io/open-traffic-generator/ixia-c-traffic-engine", "tag": "1.8.0.25"},
+		{"name": "protocol-engine", "path": "ghcr.io/open-traffic-generator/ixia-c-protocol-engine", "tag": "1.00.0.399"}
+	]}`)
+	if err := r.loadRelInfo(context.Background(), release, &data, false, DS_RESTAPI, ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testDeployingNode returns an OTG node being deployed, with its controller Deployment
+func testDeployingNode(release string) (*networkv1beta1.IxiaTG, *appsv1.Deployment) {
+	ixia := testNode("otg", "eth1")
+	ixia.Spec.Release = release
+	ixia.Spec.DesiredState = STATE_DEPLOYED
+	ixia.Status.State = STATE_INITED
+	ixia.Status.Interfaces = []networkv1beta1.IxiaTGIntfStatus{{PodName: "otg-port-eth1", Name: "eth1", Intf: "eth1"}}
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "otg" + CTRL_POD_NAME_SUFFIX, Namespace: ixia.Namespace, CreationTimestamp: metav1.Now()},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "otg" + CTRL_POD_NAME_SUFFIX}},
+		},
+	}
+	return ixia, deploy
+}
+
+func TestReconcileDeployFailure(t *testing.T) {
+	ixia, deploy := testDeployingNode("test-deploy-failure")
+	funcs := interceptor.Funcs{
+		Apply: func(ctx context.Context, c client.WithWatch, obj runtime.ApplyConfiguration, opts ...client.ApplyOption) error {
+			return errors.New("injected apply failure")
+		},
+	}
+	r := testReconcilerWithFuncs(t, funcs, ixia, deploy)
+	testRelease(t, r, ixia.Spec.Release)
+
+	ctx := context.Background()
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ixia)}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	got := &networkv1beta1.IxiaTG{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(ixia), got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.State != STATE_FAILED || !strings.Contains(got.Status.Reason, "injected apply failure") {
+		t.Errorf("status = %v (%v), want %v with deploy failure", got.Status.State, got.Status.Reason, STATE_FAILED)
+	}
+}
+
+func TestReconcileRolloutDeadline(t *testing.T) {
+	ixia, deploy := testDeployingNode("test-rollout-deadline")
+	deploy.Status.Conditions = []appsv1.DeploymentCondition{{
+		Type:    appsv1.DeploymentProgressing,
+		Status:  corev1.ConditionFalse,
+		Reason:  DEPLOYMENT_PROGRESS_DEADLINE_EXCEEDED,
+		Message: "ReplicaSet has timed out progressing",
+	}}
+	r := testReconciler(t, ixia, deploy)
+	testRelease(t, r, ixia.Spec.Release)
+
+	ctx := context.Background()
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ixia)}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	got := &networkv1beta1.IxiaTG{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(ixia), got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.State != STATE_FAILED || !strings.Contains(got.Status.Reason, "timed out progressing") {
+		t.Errorf("status = %v (%v), want %v with rollout failure", got.Status.State, got.Status.Reason, STATE_FAILED)
+	}
+}
+
+func TestValidateSecurity(t *testing.T) {
+	tests := []struct {
+		name     string
+		security networkv1beta1.IxiaTGSecurity
+		wantErr  bool
+	}{
+		{"default", networkv1beta1.IxiaTGSecurity{}, false},
+		{"privileged", networkv1beta1.IxiaTGSecurity{Profile: SECURITY_PRIVILEGED}, false},
+		{"unprivileged", networkv1beta1.IxiaTGSecurity{Profile: SECURITY_UNPRIVILEGED, SeccompProfile: "RuntimeDefault"}, false},
+		{"localhost seccomp", networkv1beta1.IxiaTGSecurity{SeccompProfile: "Localhost", SeccompLocalhostProfile: "profiles/ixia-c.json"}, false},
+		{"unknown profile", networkv1beta1.IxiaTGSecurity{Profile: "restricted"}, true},
+		{"unknown seccomp profile", networkv1beta1.IxiaTGSecurity{SeccompProfile: "Strict"}, true},
+		{"localhost seccomp without file", networkv1beta1.IxiaTGSecurity{SeccompProfile: "Localhost"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ixia := testNode("otg")
+			ixia.Spec.Security = tt.security
+			if err := validateSecurity(ixia); (err != nil) != tt.wantErr {
+				t.Errorf("validateSecurity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetSecurityContext(t *testing.T) {
+	tests := []struct {
+		name           string
+		security       networkv1beta1.IxiaTGSecurity
+		operatorDef    string
+		wantPrivileged bool
+		wantCaps       []corev1.Capability
+		wantSeccomp    corev1.SeccompProfileType
+	}{
+		{"operator default privileged", networkv1beta1.IxiaTGSecurity{}, SECURITY_PRIVILEGED, true, nil, ""},
+		{"operator default unset", networkv1beta1.IxiaTGSecurity{}, "", true, nil, ""},
+		{"operator default unprivileged", networkv1beta1.IxiaTGSecurity{}, SECURITY_UNPRIVILEGED, false,
+			[]corev1.Capability{"NET_ADMIN", "NET_RAW", "IPC_LOCK", "SYS_NICE", "SYS_RESOURCE"}, corev1.SeccompProfileTypeRuntimeDefault},
+		{"spec overrides operator default", networkv1beta1.IxiaTGSecurity{Profile: SECURITY_PRIVILEGED}, SECURITY_UNPRIVILEGED, true, nil, ""},
+		{"spec capabilities", networkv1beta1.IxiaTGSecurity{Profile: SECURITY_UNPRIVILEGED, Capabilities: []string{"cap_net_admin", "NET_RAW"}}, SECURITY_PRIVILEGED, false,
+			[]corev1.Capability{"NET_ADMIN", "NET_RAW"}, corev1.SeccompProfileTypeRuntimeDefault},
+		{"spec seccomp", networkv1beta1.IxiaTGSecurity{Profile: SECURITY_UNPRIVILEGED, SeccompProfile: "Unconfined"}, "", false,
+			[]corev1.Capability{"NET_ADMIN", "NET_RAW", "IPC_LOCK", "SYS_NICE", "SYS_RESOURCE"}, corev1.SeccompProfileTypeUnconfined},
+		{"privileged with seccomp", networkv1beta1.IxiaTGSecurity{SeccompProfile: "Localhost", SeccompLocalhostProfile: "profiles/ixia-c.json"}, "", true, nil, corev1.SeccompProfileTypeLocalhost},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ixia := testNode("otg")
+			ixia.Spec.Security = tt.security
+			r := &IxiaTGReconciler{DefaultSecurityProfile: tt.operatorDef}
+			sc := r.getSecurityContext(ixia)
+			if sc.Privileged == nil || *sc.Privileged != tt.wantPrivileged {
+				t.Errorf("getSecurityContext() privileged %v, want %v", sc.Privileged, tt.wantPrivileged)
+			}
+			var caps []corev1.Capability
+			if sc.Capabilities != nil {
+				caps = sc.Capabilities.Add
+			}
+			if !reflect.DeepEqual(caps, tt.wantCaps) {
+				t.Errorf("getSecurityContext() capabilities %v, want %v", caps, tt.wantCaps)
+			}
+			var seccomp corev1.SeccompProfileType
+			if sc.SeccompProfile != nil {
+				seccomp = sc.SeccompProfile.Type
+			}
+			if seccomp != tt.wantSeccomp {
+				t.Errorf("getSecurityContext() seccomp profile %v, want %v", seccomp, tt.wantSeccomp)
+			}
+			if seccomp == corev1.SeccompProfileTypeLocalhost && *sc.SeccompProfile.LocalhostProfile != tt.security.SeccompLocalhostProfile {
+				t.Errorf("getSecurityContext() localhost profile %v, want %v", *sc.SeccompProfile.LocalhostProfile, tt.security.SeccompLocalhostProfile)
+			}
+		})
+	}
+}
+
+func TestCreatePortPods(t *testing.T) {
+	ixia := testNode("otg", "eth1", "eth2", "eth3", "eth4", "eth5", "eth6")
+	ixia.Spec.Release = "test-create-port-pods"
+	podMap := map[string][]string{}
+	for _, intf := range ixia.Spec.Interfaces {
+		podMap["otg-port-"+intf.Name] = []string{intf.Name}
+	}
+	failing := map[string]bool{"otg-port-eth2": true, "otg-port-eth5": true}
+
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	applied := map[string]int{}
+	funcs := interceptor.Funcs{
+		Apply: func(ctx context.Context, c client.WithWatch, obj runtime.ApplyConfiguration, opts ...client.ApplyOption) error {
+			meta, ok := obj.(interface {
+				GetName() string
+				GetKind() string
+			})
+			if !ok || meta.GetKind() != "Pod" {
+				return c.Apply(ctx, obj, opts...)
+			}
+			mu.Lock()
+			applied[meta.GetName()]++
+			inFlight++
+			if inFlight > maxInFlight {
+				maxInFlight = inFlight
+			}
+			mu.Unlock()
+			// Hold the create so concurrent ones overlap
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			if failing[meta.GetName()] {
+				return errors.New("injected create failure")
+			}
+			return c.Apply(ctx, obj, opts...)
+		},
+	}
+	r := testReconcilerWithFuncs(t, funcs, ixia)
+	r.CreateParallelism = 2
+	testRelease(t, r, ixia.Spec.Release)
+
+	err := r.createPortPods(context.Background(), podMap, ixia)
+	if err == nil {
+		t.Fatalf("createPortPods() error = %v, wantErr %v", err, true)
+	}
+	for name := range failing {
+		if !strings.Contains(err.Error(), name+": injected create failure") {
+			t.Errorf("createPortPods() error = %v, missing %v", err, name)
+		}
+	}
+	if !strings.Contains(err.Error(), "Failed to create 2 of 6 pods") {
+		t.Errorf("createPortPods() error = %v, want 2 of 6 pods failed", err)
+	}
+	if maxInFlight > r.CreateParallelism {
+		t.Errorf("createPortPods() created %d pods concurrently, want at most %d", maxInFlight, r.CreateParallelism)
+	}
+	for name := range podMap {
+		if applied[name] != 1 {
+			t.Errorf("pod %v created %d times, want 1", name, applied[name])
+		}
+		pod := &corev1.Pod{}
+		err := r.Get(context.Background(), types.NamespacedName{Name: name, Namespace: ixia.Namespace}, pod)
+		if failing[name] != (err != nil) {
+			t.Errorf("pod %v get error = %v, want created %v", name, err, !failing[name])
+		}
+	}
+}
+
+func TestDeleteSecrets(t *testing.T) {
+	secret := func(name string, replicatedFrom string) *corev1.Secret {
+		s := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ixia-c"}}
+		if replicatedFrom != "" {
+			s.Labels = map[string]string{REPLICATED_FROM_LABEL: replicatedFrom}
+		}
+		return s
+	}
+	deleting := testNode("otg-deleting", "eth1")
+	deleting.Finalizers = []string{"keysight.com/finalizer"}
+	deleting.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	tests := []struct {
+		name        string
+		others      []client.Object
+		secret      *corev1.Secret
+		wantDeleted bool
+	}{
+		{"replica", nil, secret(LIC_SERVER_SECRET, CURRENT_NAMESPACE+"."+LIC_SERVER_SECRET), true},
+		{"user secret of same name", nil, secret(LIC_SERVER_SECRET, ""), false},
+		{"replica of other source", nil, secret(LIC_SERVER_SECRET, "other-ns."+LIC_SERVER_SECRET), false},
+		{"replica used by live node", []client.Object{testNode("otg-live", "eth1")}, secret(LIC_SERVER_SECRET, CURRENT_NAMESPACE+"."+LIC_SERVER_SECRET), false},
+		{"other node being deleted", []client.Object{deleting}, secret(LIC_SERVER_SECRET, CURRENT_NAMESPACE+"."+LIC_SERVER_SECRET), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ixia := testNode("otg", "eth1")
+			r := testReconciler(t, append([]client.Object{ixia, tt.secret}, tt.others...)...)
+
+			ctx := context.Background()
+			if err := r.DeleteSecrets(ctx, ixia); err != nil {
+				t.Fatalf("DeleteSecrets() error = %v", err)
+			}
+			err := r.Get(ctx, client.ObjectKeyFromObject(tt.secret), &corev1.Secret{})
+			if deleted := errapi.IsNotFound(err); deleted != tt.wantDeleted {
+				t.Errorf("secret deleted %v, want %v (%v)", deleted, tt.wantDeleted, err)
+			}
+		})
+	}
+}
+
+func TestReconcileSecretsFailure(t *testing.T) {
+	ixia, deploy := testDeployingNode("test-secrets-failure")
+	failing := false
+	funcs := interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if _, ok := obj.(*corev1.Secret); ok && failing && key.Namespace == CURRENT_NAMESPACE {
+				return errors.New("injected secret failure")
+			}
+			return c.Get(ctx, key, obj, opts...)
+		},
+	}
+	r := testReconcilerWithFuncs(t, funcs, ixia, deploy)
+	testRelease(t, r, ixia.Spec.Release)
+	failing = true
+
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ixia)})
+	if err == nil || !strings.Contains(err.Error(), "injected secret failure") {
+		t.Errorf("Reconcile() error = %v, want secret sync failure", err)
+	}
+}
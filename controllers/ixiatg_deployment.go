@@ -0,0 +1,188 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	networkv1beta1 "github.com/open-traffic-generator/keng-operator/api/v1beta1"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DEPLOYMENT_PROGRESS_DEADLINE_EXCEEDED string = "ProgressDeadlineExceeded"
+)
+
+// controllerDeployment wraps the generated controller pod in a single replica Deployment owned by the IxiaTG;
+// Recreate strategy ensures two controllers never serve the same topology
+func (r *IxiaTGReconciler) controllerDeployment(pod *corev1.Pod, ixia *networkv1beta1.IxiaTG) (*appsv1.Deployment, error) {
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+			Labels: map[string]string{
+				"app": pod.Name,
+			},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: pointer.Int32(1),
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": pod.Name,
+				},
+			},
+			Strategy: appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      pod.Labels,
+					Annotations: pod.Annotations,
+				},
+				Spec: pod.Spec,
+			},
+		},
+	}
+	if err := controllerutil.SetControllerReference(ixia, deploy, r.Scheme); err != nil {
+		return nil, err
+	}
+	return deploy, nil
+}
+
+// controllerPDB limits voluntary disruptions of the controller to one pod at a time; a minimum available
+// budget would block node drains forever, since the single replica can never be evicted
+func (r *IxiaTGReconciler) controllerPDB(deploy *appsv1.Deployment, ixia *networkv1beta1.IxiaTG) (*policyv1.PodDisruptionBudget, error) {
+	maxUnavailable := intstr.FromInt(1)
+	pdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      deploy.Name,
+			Namespace: deploy.Namespace,
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector:       deploy.Spec.Selector,
+		},
+	}
+	if err := controllerutil.SetControllerReference(ixia, pdb, r.Scheme); err != nil {
+		return nil, err
+	}
+	return pdb, nil
+}
+
+// createControllerDeployment applies the controller Deployment and its PodDisruptionBudget; a bare controller
+// pod left by an earlier operator version shares the app label, and so the Service, and is deleted
+func (r *IxiaTGReconciler) createControllerDeployment(ctx context.Context, pod *corev1.Pod, ixia *networkv1beta1.IxiaTG, release string) error {
+	deploy, err := r.controllerDeployment(pod, ixia)
+	if err != nil {
+		return err
+	}
+	legacy := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace}}
+	if err = r.deleteGenerated(ctx, legacy); err != nil {
+		return err
+	}
+	log.Infof("Applying controller deployment %v", deploy)
+	if err = r.applyGenerated(ctx, deploy, release, autoUpdate(ixia)); err != nil {
+		log.Errorf("Failed to apply deployment %v in %v, err %v", deploy.Name, deploy.Namespace, err)
+		return err
+	}
+
+	pdb, err := r.controllerPDB(deploy, ixia)
+	if err != nil {
+		return err
+	}
+	if err = r.applyGenerated(ctx, pdb, release, autoUpdate(ixia)); err != nil {
+		log.Errorf("Failed to apply pod disruption budget %v in %v, err %v", pdb.Name, pdb.Namespace, err)
+		return err
+	}
+	return nil
+}
+
+// deleteControllerDeployment deletes the controller Deployment, its pods and PodDisruptionBudget
+func (r *IxiaTGReconciler) deleteControllerDeployment(ctx context.Context, ixia *networkv1beta1.IxiaTG) error {
+	name := ixia.Name + CTRL_POD_NAME_SUFFIX
+	pdb := &policyv1.PodDisruptionBudget{}
+	if r.Get(ctx, types.NamespacedName{Name: name, Namespace: ixia.Namespace}, pdb) == nil {
+		if err := r.Delete(ctx, pdb); err != nil {
+			log.Errorf("Failed to delete pod disruption budget %v - %v", pdb.Name, err)
+			return err
+		}
+		log.Infof("Deleted pod disruption budget %v", pdb.Name)
+	}
+
+	deploy := &appsv1.Deployment{}
+	if r.Get(ctx, types.NamespacedName{Name: name, Namespace: ixia.Namespace}, deploy) == nil {
+		if err := r.Delete(ctx, deploy, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+			log.Errorf("Failed to delete controller deployment %v - %v", deploy.Name, err)
+			return err
+		}
+		log.Infof("Deleted controller deployment %v", deploy.Name)
+	}
+	return nil
+}
+
+// getControllerPods returns the live pods managed by the controller Deployment; failed (e.g. evicted)
+// and terminating pods are skipped since the Deployment replaces them
+func (r *IxiaTGReconciler) getControllerPods(ctx context.Context, deploy *appsv1.Deployment) ([]corev1.Pod, error) {
+	podList := &corev1.PodList{}
+	opts := []client.ListOption{
+		client.InNamespace(deploy.Namespace),
+		client.MatchingLabels(deploy.Spec.Selector.MatchLabels),
+	}
+	if err := r.List(ctx, podList, opts...); err != nil {
+		return nil, err
+	}
+	pods := []corev1.Pod{}
+	for _, p := range podList.Items {
+		if p.Status.Phase == corev1.PodFailed || !p.DeletionTimestamp.IsZero() {
+			continue
+		}
+		pods = append(pods, p)
+	}
+	return pods, nil
+}
+
+// deploymentRolledOut reports whether the latest revision of the Deployment is available; it fails
+// if the Deployment could not progress within its deadline
+func deploymentRolledOut(deploy *appsv1.Deployment) (bool, error) {
+	for _, c := range deploy.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Status == corev1.ConditionFalse && c.Reason == DEPLOYMENT_PROGRESS_DEADLINE_EXCEEDED {
+			return false, errors.New(fmt.Sprintf("Deployment %s failed - %s", deploy.Name, c.Message))
+		}
+	}
+	if deploy.Status.ObservedGeneration < deploy.Generation {
+		return false, nil
+	}
+	replicas := int32(1)
+	if deploy.Spec.Replicas != nil {
+		replicas = *deploy.Spec.Replicas
+	}
+	if deploy.Status.UpdatedReplicas < replicas || deploy.Status.AvailableReplicas < replicas {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	errapi "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestCreateControllerDeployment(t *testing.T) {
+	ixia := testNode("otg", "eth1")
+	name := "otg" + CTRL_POD_NAME_SUFFIX
+	legacy := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ixia.Namespace, Labels: map[string]string{"app": name}},
+	}
+	r := testReconciler(t, ixia, legacy)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ixia.Namespace, Labels: map[string]string{"app": name}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: CONTROLLER_NAME, Image: "keng-controller"}}},
+	}
+
+	ctx := context.Background()
+	if err := r.createControllerDeployment(ctx, pod, ixia, "local"); err != nil {
+		t.Fatalf("createControllerDeployment() error = %v", err)
+	}
+	key := types.NamespacedName{Name: name, Namespace: ixia.Namespace}
+	if err := r.Get(ctx, key, &corev1.Pod{}); !errapi.IsNotFound(err) {
+		t.Errorf("legacy controller pod not deleted, get error = %v", err)
+	}
+	if err := r.Get(ctx, key, &appsv1.Deployment{}); err != nil {
+		t.Errorf("controller deployment not created - %v", err)
+	}
+	pdb := &policyv1.PodDisruptionBudget{}
+	if err := r.Get(ctx, key, pdb); err != nil {
+		t.Fatalf("pod disruption budget not created - %v", err)
+	}
+	if pdb.Spec.MinAvailable != nil || pdb.Spec.MaxUnavailable == nil || pdb.Spec.MaxUnavailable.IntValue() != 1 {
+		t.Errorf("pod disruption budget must allow one unavailable pod, got %+v", pdb.Spec)
+	}
+}
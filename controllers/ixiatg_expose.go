@@ -0,0 +1,469 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	networkv1beta1 "github.com/open-traffic-generator/keng-operator/api/v1beta1"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	EXPOSE_LOAD_BALANCER string = "LoadBalancer"
+	EXPOSE_INGRESS       string = "Ingress"
+	EXPOSE_GATEWAY       string = "Gateway"
+
+	HTTP_ENDPOINT_NAME string = "http"
+	GRPC_ENDPOINT_NAME string = "grpc"
+	GNMI_ENDPOINT_NAME string = "gnmi"
+
+	// Controller, gNMI and gRPC server arguments for listening and target ports
+	CTRL_HTTP_PORT_ARG   string = "--http-port"
+	CTRL_GRPC_PORT_ARG   string = "--grpc-port"
+	GNMI_SERVER_PORT_ARG string = "--server-port"
+	GNMI_NEW_PORT_ARG    string = "-server-port"
+	GNMI_HTTP_SERVER_ARG string = "-http-server"
+	TARGET_PORT_ARG      string = "--target-port"
+
+	INGRESS_NAME_PREFIX string = "ingress-"
+	ROUTE_NAME_PREFIX   string = "route-"
+
+	GATEWAY_API_GROUP   string = "gateway.networking.k8s.io"
+	GATEWAY_API_VERSION string = "v1"
+	HTTP_ROUTE_KIND     string = "HTTPRoute"
+	GRPC_ROUTE_KIND     string = "GRPCRoute"
+
+	BACKEND_TLS_NAME_PREFIX string = "backendtls-"
+	BACKEND_TLS_POLICY_KIND string = "BackendTLSPolicy"
+	WELL_KNOWN_CA_SYSTEM    string = "System"
+
+	NGINX_BACKEND_PROTOCOL string = "nginx.ingress.kubernetes.io/backend-protocol"
+)
+
+// exposeType returns the configured exposure of an api endpoint
+func exposeType(svc networkv1beta1.IxiaTGSvcPort) string {
+	if svc.Expose == nil || svc.Expose.Type == "" {
+		return EXPOSE_LOAD_BALANCER
+	}
+	return svc.Expose.Type
+}
+
+// exposeHost returns the host name used for routing to an api endpoint
+func exposeHost(name string, svc networkv1beta1.IxiaTGSvcPort, namespace string) string {
+	if svc.Expose == nil {
+		return ""
+	}
+	if svc.Expose.Host != "" {
+		return svc.Expose.Host
+	}
+	return name + "." + namespace + "." + svc.Expose.Domain
+}
+
+// validateApiEndPoints verifies the exposure configuration of all api endpoints
+func validateApiEndPoints(ixia *networkv1beta1.IxiaTG) error {
+	ports := map[int32]string{}
+	for name, svc := range ixia.Spec.ApiEndPoint {
+		if svc.In < 1 || svc.In > 65535 {
+			return errors.New(fmt.Sprintf("Invalid port %d for api endpoint %s", svc.In, name))
+		} else if other, ok := ports[svc.In]; ok {
+			return errors.New(fmt.Sprintf("Port %d of api endpoint %s already used by %s", svc.In, name, other))
+		}
+		ports[svc.In] = name
+		switch exposeType(svc) {
+		case EXPOSE_LOAD_BALANCER:
+		case EXPOSE_INGRESS:
+			if svc.Expose.Host == "" && svc.Expose.Domain == "" {
+				return errors.New(fmt.Sprintf("Either host or domain is required to expose api endpoint %s through %s", name, EXPOSE_INGRESS))
+			}
+		case EXPOSE_GATEWAY:
+			if svc.Expose.Host == "" && svc.Expose.Domain == "" {
+				return errors.New(fmt.Sprintf("Either host or domain is required to expose api endpoint %s through %s", name, EXPOSE_GATEWAY))
+			}
+			if svc.Expose.Gateway == "" {
+				return errors.New(fmt.Sprintf("Gateway name is required to expose api endpoint %s through %s", name, EXPOSE_GATEWAY))
+			}
+			if backendTLS(name, ixia) && ixia.Spec.TLS == nil {
+				return errors.New(fmt.Sprintf("TLS is required to expose api endpoint %s through %s, for the gateway to verify the controller certificate", name, EXPOSE_GATEWAY))
+			}
+		default:
+			return errors.New(fmt.Sprintf("Unsupported exposure type %s for api endpoint %s", svc.Expose.Type, name))
+		}
+	}
+	return nil
+}
+
+func routeGVK(name string) schema.GroupVersionKind {
+	kind := GRPC_ROUTE_KIND
+	if name == HTTP_ENDPOINT_NAME {
+		kind = HTTP_ROUTE_KIND
+	}
+	return schema.GroupVersionKind{Group: GATEWAY_API_GROUP, Version: GATEWAY_API_VERSION, Kind: kind}
+}
+
+// gatewayObject returns a Gateway API object of the given kind, identified by name
+func gatewayObject(gvk schema.GroupVersionKind, name string, namespace string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetName(name)
+	obj.SetNamespace(namespace)
+	return obj
+}
+
+// backendTLS reports whether the backend of an api endpoint serves TLS; the controller always serves HTTPS
+// and gNMI serves TLS only with spec tls
+func backendTLS(name string, ixia *networkv1beta1.IxiaTG) bool {
+	return name == HTTP_ENDPOINT_NAME || (name == GNMI_ENDPOINT_NAME && ixia.Spec.TLS != nil)
+}
+
+// backendHost returns the DNS name of the service of an api endpoint, which the controller certificate is
+// verified against by gateways
+func backendHost(name string, ixia *networkv1beta1.IxiaTG) string {
+	return "service-" + name + "-" + ixia.Name + CTRL_POD_NAME_SUFFIX + "." + ixia.Namespace + ".svc"
+}
+
+func (r *IxiaTGReconciler) getControllerIngress(name string, svc networkv1beta1.IxiaTGSvcPort, ixia *networkv1beta1.IxiaTG) (*networkingv1.Ingress, error) {
+	annotations := map[string]string{NGINX_BACKEND_PROTOCOL: "GRPC"}
+	if name == HTTP_ENDPOINT_NAME {
+		annotations[NGINX_BACKEND_PROTOCOL] = "HTTPS"
+	} else if backendTLS(name, ixia) {
+		annotations[NGINX_BACKEND_PROTOCOL] = "GRPCS"
+	}
+	for k, v := range svc.Expose.Annotations {
+		annotations[k] = v
+	}
+
+	svcName := ixia.Name + CTRL_POD_NAME_SUFFIX
+	pathType := networkingv1.PathTypePrefix
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        INGRESS_NAME_PREFIX + name + "-" + svcName,
+			Namespace:   ixia.Namespace,
+			Annotations: annotations,
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{{
+				Host: exposeHost(name, svc, ixia.Namespace),
+				IngressRuleValue: networkingv1.IngressRuleValue{
+					HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: []networkingv1.HTTPIngressPath{{
+							Path:     "/",
+							PathType: &pathType,
+							Backend: networkingv1.IngressBackend{
+								Service: &networkingv1.IngressServiceBackend{
+									Name: "service-" + name + "-" + svcName,
+									Port: networkingv1.ServiceBackendPort{Number: svc.In},
+								},
+							},
+						}},
+					},
+				},
+			}},
+		},
+	}
+	if svc.Expose.IngressClass != "" {
+		ingress.Spec.IngressClassName = &svc.Expose.IngressClass
+	}
+	if err := controllerutil.SetControllerReference(ixia, ingress, r.Scheme); err != nil {
+		return nil, err
+	}
+	return ingress, nil
+}
+
+func (r *IxiaTGReconciler) getControllerRoute(name string, svc networkv1beta1.IxiaTGSvcPort, ixia *networkv1beta1.IxiaTG) (*unstructured.Unstructured, error) {
+	gwNamespace := svc.Expose.GatewayNamespace
+	if gwNamespace == "" {
+		gwNamespace = ixia.Namespace
+	}
+
+	svcName := ixia.Name + CTRL_POD_NAME_SUFFIX
+	route := gatewayObject(routeGVK(name), ROUTE_NAME_PREFIX+name+"-"+svcName, ixia.Namespace)
+	if len(svc.Expose.Annotations) > 0 {
+		route.SetAnnotations(svc.Expose.Annotations)
+	}
+	route.Object["spec"] = map[string]interface{}{
+		"parentRefs": []interface{}{
+			map[string]interface{}{
+				"name":      svc.Expose.Gateway,
+				"namespace": gwNamespace,
+			},
+		},
+		"hostnames": []interface{}{exposeHost(name, svc, ixia.Namespace)},
+		"rules": []interface{}{
+			map[string]interface{}{
+				"backendRefs": []interface{}{
+					map[string]interface{}{
+						"name": "service-" + name + "-" + svcName,
+						"port": int64(svc.In),
+					},
+				},
+			},
+		},
+	}
+	if err := controllerutil.SetControllerReference(ixia, route, r.Scheme); err != nil {
+		return nil, err
+	}
+	return route, nil
+}
+
+// getBackendTLSPolicy returns the policy for the gateway to originate TLS to the service of an api endpoint;
+// the certificate is verified against the CA of the TLS secret, if present, or the system CAs otherwise
+func (r *IxiaTGReconciler) getBackendTLSPolicy(name string, ixia *networkv1beta1.IxiaTG, caBundle bool) (*unstructured.Unstructured, error) {
+	svcName := ixia.Name + CTRL_POD_NAME_SUFFIX
+	policy := gatewayObject(backendTLSPolicyGVK(), BACKEND_TLS_NAME_PREFIX+name+"-"+svcName, ixia.Namespace)
+	validation := map[string]interface{}{
+		"hostname":                backendHost(name, ixia),
+		"wellKnownCACertificates": WELL_KNOWN_CA_SYSTEM,
+	}
+	if caBundle {
+		delete(validation, "wellKnownCACertificates")
+		validation["caCertificateRefs"] = []interface{}{
+			map[string]interface{}{
+				"group": "",
+				"kind":  "ConfigMap",
+				"name":  ixia.Name + TLS_CA_SUFFIX,
+			},
+		}
+	}
+	policy.Object["spec"] = map[string]interface{}{
+		"targetRefs": []interface{}{
+			map[string]interface{}{
+				"group": "",
+				"kind":  "Service",
+				"name":  "service-" + name + "-" + svcName,
+			},
+		},
+		"validation": validation,
+	}
+	if err := controllerutil.SetControllerReference(ixia, policy, r.Scheme); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func backendTLSPolicyGVK() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: GATEWAY_API_GROUP, Version: GATEWAY_API_VERSION, Kind: BACKEND_TLS_POLICY_KIND}
+}
+
+// getBackendCA returns the ConfigMap with the CA certificate of the TLS secret, referenced by backend TLS
+// policies; nil if TLS is not enabled or the secret carries no CA certificate
+func (r *IxiaTGReconciler) getBackendCA(ctx context.Context, ixia *networkv1beta1.IxiaTG) (*corev1.ConfigMap, error) {
+	if ixia.Spec.TLS == nil {
+		return nil, nil
+	}
+	secret, err := r.GetSecret(ctx, tlsSecretName(ixia), ixia.Namespace)
+	if err != nil || secret == nil || len(secret.Data[TLS_CA_CERT_KEY]) == 0 {
+		return nil, err
+	}
+	cfgMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ixia.Name + TLS_CA_SUFFIX,
+			Namespace: ixia.Namespace,
+			Labels:    map[string]string{NODE_LABEL: ixia.Name},
+		},
+		Data: map[string]string{TLS_CA_CERT_KEY: string(secret.Data[TLS_CA_CERT_KEY])},
+	}
+	if err = controllerutil.SetControllerReference(ixia, cfgMap, r.Scheme); err != nil {
+		return nil, err
+	}
+	return cfgMap, nil
+}
+
+// exposeController applies the Ingress or Gateway API routes for the controller api endpoints, along with
+// backend TLS policies for routes to endpoints serving TLS; objects of a previous exposure are deleted
+func (r *IxiaTGReconciler) exposeController(ctx context.Context, ixia *networkv1beta1.IxiaTG, release string) error {
+	caCfgMap, err := r.getBackendCA(ctx, ixia)
+	if err != nil {
+		return err
+	}
+	caUsed := false
+	for _, name := range sortedEndpoints(ixia) {
+		svc := ixia.Spec.ApiEndPoint[name]
+		switch exposeType(svc) {
+		case EXPOSE_INGRESS:
+			ingress, err := r.getControllerIngress(name, svc, ixia)
+			if err != nil {
+				return err
+			}
+			if err = r.applyGenerated(ctx, ingress, release, autoUpdate(ixia)); err != nil {
+				log.Errorf("Failed to apply ingress %v in %v, err %v", ingress.Name, ixia.Namespace, err)
+				return err
+			}
+			log.Infof("Applied ingress %v for host %v", ingress.Name, ingress.Spec.Rules[0].Host)
+		case EXPOSE_GATEWAY:
+			route, err := r.getControllerRoute(name, svc, ixia)
+			if err != nil {
+				return err
+			}
+			if err = r.applyGenerated(ctx, route, release, autoUpdate(ixia)); err != nil {
+				log.Errorf("Failed to apply %v %v in %v, err %v", route.GetKind(), route.GetName(), ixia.Namespace, err)
+				return err
+			}
+			log.Infof("Applied %v %v for host %v", route.GetKind(), route.GetName(), exposeHost(name, svc, ixia.Namespace))
+			if !backendTLS(name, ixia) {
+				break
+			}
+			policy, err := r.getBackendTLSPolicy(name, ixia, caCfgMap != nil)
+			if err != nil {
+				return err
+			}
+			if err = r.applyGenerated(ctx, policy, release, autoUpdate(ixia)); err != nil {
+				log.Errorf("Failed to apply %v %v in %v, err %v", policy.GetKind(), policy.GetName(), ixia.Namespace, err)
+				return err
+			}
+			caUsed = caCfgMap != nil
+		}
+		if err = r.unexposeEndpoint(ctx, ixia, name, exposeType(svc)); err != nil {
+			return err
+		}
+	}
+	if caUsed {
+		if err = r.applyGenerated(ctx, caCfgMap, release, autoUpdate(ixia)); err != nil {
+			log.Errorf("Failed to apply config map %v in %v, err %v", caCfgMap.Name, ixia.Namespace, err)
+			return err
+		}
+	} else if err = r.deleteGenerated(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: ixia.Name + TLS_CA_SUFFIX, Namespace: ixia.Namespace}}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// unexposeEndpoint deletes the Ingress, route and backend TLS policy of an api endpoint, except those of
+// the kept exposure type
+func (r *IxiaTGReconciler) unexposeEndpoint(ctx context.Context, ixia *networkv1beta1.IxiaTG, name string, keep string) error {
+	svcName := ixia.Name + CTRL_POD_NAME_SUFFIX
+	objs := []client.Object{}
+	if keep != EXPOSE_INGRESS {
+		objs = append(objs, &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: INGRESS_NAME_PREFIX + name + "-" + svcName, Namespace: ixia.Namespace},
+		})
+	}
+	if keep != EXPOSE_GATEWAY {
+		objs = append(objs, gatewayObject(routeGVK(name), ROUTE_NAME_PREFIX+name+"-"+svcName, ixia.Namespace))
+	}
+	if keep != EXPOSE_GATEWAY || !backendTLS(name, ixia) {
+		objs = append(objs, gatewayObject(backendTLSPolicyGVK(), BACKEND_TLS_NAME_PREFIX+name+"-"+svcName, ixia.Namespace))
+	}
+	for _, obj := range objs {
+		if err := r.deleteGenerated(ctx, obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unexposeController deletes the Ingress, Gateway API routes and backend TLS policies of all controller api
+// endpoints, whatever their current exposure type
+func (r *IxiaTGReconciler) unexposeController(ctx context.Context, ixia *networkv1beta1.IxiaTG) error {
+	for name := range ixia.Spec.ApiEndPoint {
+		if err := r.unexposeEndpoint(ctx, ixia, name, ""); err != nil {
+			return err
+		}
+	}
+	return r.deleteGenerated(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: ixia.Name + TLS_CA_SUFFIX, Namespace: ixia.Namespace}})
+}
+
+// exposedHosts returns the host names of all api endpoints exposed through Ingress or Gateway
+func exposedHosts(ixia *networkv1beta1.IxiaTG) []string {
+	hosts := []string{}
+	for name, svc := range ixia.Spec.ApiEndPoint {
+		if exposeType(svc) != EXPOSE_LOAD_BALANCER {
+			hosts = append(hosts, exposeHost(name, svc, ixia.Namespace))
+		}
+	}
+	return hosts
+}
+
+// controllerPorts defines the ports controller containers listen on
+type controllerPorts struct {
+	http int32
+	grpc int32
+	gnmi int32
+}
+
+// endpointPorts returns the controller ports as per the api endpoints in spec, defaulting to the standard ports
+func endpointPorts(ixia *networkv1beta1.IxiaTG) controllerPorts {
+	ports := controllerPorts{http: CTRL_HTTPS_PORT, grpc: CTRL_GRPC_PORT, gnmi: CTRL_GNMI_PORT}
+	if svc, ok := ixia.Spec.ApiEndPoint[HTTP_ENDPOINT_NAME]; ok && svc.In != 0 {
+		ports.http = svc.In
+	}
+	if svc, ok := ixia.Spec.ApiEndPoint[GRPC_ENDPOINT_NAME]; ok && svc.In != 0 {
+		ports.grpc = svc.In
+	}
+	if svc, ok := ixia.Spec.ApiEndPoint[GNMI_ENDPOINT_NAME]; ok && svc.In != 0 {
+		ports.gnmi = svc.In
+	}
+	return ports
+}
+
+// setArg sets the value following flag in a copy of args, as args may be shared with the release info
+// cache; the flag is appended if not present and add is set
+func setArg(args []string, flag string, value string, add bool) []string {
+	args = append([]string{}, args...)
+	for index, arg := range args {
+		if arg == flag && index+1 < len(args) {
+			args[index+1] = value
+			return args
+		}
+	}
+	if add {
+		args = append(args, flag, value)
+	}
+	return args
+}
+
+// setEndpointPorts points the listening and target ports of controller containers at the controller ports;
+// standard ports are left to container defaults
+func setEndpointPorts(cont *corev1.Container, ports controllerPorts, newGNMI bool) {
+	http := strconv.Itoa(int(ports.http))
+	switch cont.Name {
+	case CONTROLLER_NAME:
+		cont.Args = setArg(cont.Args, CTRL_GRPC_PORT_ARG, strconv.Itoa(int(ports.grpc)), ports.grpc != CTRL_GRPC_PORT)
+		cont.Args = setArg(cont.Args, CTRL_HTTP_PORT_ARG, http, ports.http != CTRL_HTTPS_PORT)
+	case GNMI_NAME:
+		if newGNMI && len(cont.Command) == 0 {
+			cont.Args = setArg(cont.Args, GNMI_HTTP_SERVER_ARG, "https://localhost:"+http, false)
+			cont.Args = setArg(cont.Args, GNMI_NEW_PORT_ARG, strconv.Itoa(int(ports.gnmi)), ports.gnmi != CTRL_GNMI_PORT)
+		} else {
+			cont.Command = setArg(cont.Command, GNMI_SERVER_PORT_ARG, strconv.Itoa(int(ports.gnmi)), false)
+			cont.Command = setArg(cont.Command, TARGET_PORT_ARG, http, false)
+		}
+	case GRPC_NAME:
+		cont.Command = setArg(cont.Command, TARGET_PORT_ARG, http, false)
+		cont.Command = setArg(cont.Command, GNMI_SERVER_PORT_ARG, strconv.Itoa(int(ports.grpc)), ports.grpc != CTRL_GRPC_PORT)
+	}
+	for index := range cont.Ports {
+		switch cont.Name {
+		case GNMI_NAME:
+			cont.Ports[index].ContainerPort = ports.gnmi
+		case GRPC_NAME:
+			cont.Ports[index].ContainerPort = ports.grpc
+		}
+	}
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	errapi "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	networkv1beta1 "github.com/open-traffic-generator/keng-operator/api/v1beta1"
+)
+
+func exposedNode(tls *networkv1beta1.IxiaTGTLS, expose map[string]string) *networkv1beta1.IxiaTG {
+	ixia := testNode("otg", "eth1")
+	ixia.Spec.TLS = tls
+	ixia.Spec.ApiEndPoint = map[string]networkv1beta1.IxiaTGSvcPort{}
+	ports := map[string]int32{HTTP_ENDPOINT_NAME: CTRL_HTTPS_PORT, GRPC_ENDPOINT_NAME: CTRL_GRPC_PORT, GNMI_ENDPOINT_NAME: CTRL_GNMI_PORT}
+	for name, exposeType := range expose {
+		ixia.Spec.ApiEndPoint[name] = networkv1beta1.IxiaTGSvcPort{
+			In:     ports[name],
+			Expose: &networkv1beta1.IxiaTGSvcExpose{Type: exposeType, Domain: "lab.example.com", Gateway: "gw"},
+		}
+	}
+	return ixia
+}
+
+func TestValidateApiEndPoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		tls     *networkv1beta1.IxiaTGTLS
+		expose  map[string]string
+		wantErr bool
+	}{
+		{"load balancer", nil, map[string]string{HTTP_ENDPOINT_NAME: EXPOSE_LOAD_BALANCER}, false},
+		{"ingress without tls", nil, map[string]string{HTTP_ENDPOINT_NAME: EXPOSE_INGRESS, GNMI_ENDPOINT_NAME: EXPOSE_INGRESS}, false},
+		{"gateway grpc without tls", nil, map[string]string{GRPC_ENDPOINT_NAME: EXPOSE_GATEWAY, GNMI_ENDPOINT_NAME: EXPOSE_GATEWAY}, false},
+		{"gateway http without tls", nil, map[string]string{HTTP_ENDPOINT_NAME: EXPOSE_GATEWAY}, true},
+		{"gateway http with tls", &networkv1beta1.IxiaTGTLS{Generate: true}, map[string]string{HTTP_ENDPOINT_NAME: EXPOSE_GATEWAY}, false},
+		{"unknown type", nil, map[string]string{HTTP_ENDPOINT_NAME: "NodePort"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateApiEndPoints(exposedNode(tt.tls, tt.expose))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateApiEndPoints() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestControllerIngressBackendProtocol(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		tls      *networkv1beta1.IxiaTGTLS
+		want     string
+	}{
+		{"http", HTTP_ENDPOINT_NAME, nil, "HTTPS"},
+		{"grpc", GRPC_ENDPOINT_NAME, nil, "GRPC"},
+		{"gnmi insecure", GNMI_ENDPOINT_NAME, nil, "GRPC"},
+		{"gnmi tls", GNMI_ENDPOINT_NAME, &networkv1beta1.IxiaTGTLS{Generate: true}, "GRPCS"},
+	}
+	r := testReconciler(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ixia := exposedNode(tt.tls, map[string]string{tt.endpoint: EXPOSE_INGRESS})
+			ingress, err := r.getControllerIngress(tt.endpoint, ixia.Spec.ApiEndPoint[tt.endpoint], ixia)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ingress.Annotations[NGINX_BACKEND_PROTOCOL]; got != tt.want {
+				t.Errorf("backend protocol = %v, want %v", got, tt.want)
+			}
+			if len(ingress.OwnerReferences) != 1 || ingress.OwnerReferences[0].UID != ixia.UID {
+				t.Errorf("owner references = %v, want %v", ingress.OwnerReferences, ixia.UID)
+			}
+		})
+	}
+}
+
+func TestExposeControllerSwitchesExposure(t *testing.T) {
+	ctx := context.Background()
+	ixia := exposedNode(nil, map[string]string{GRPC_ENDPOINT_NAME: EXPOSE_INGRESS})
+	r := testReconciler(t, ixia)
+	ingressName := types.NamespacedName{Name: INGRESS_NAME_PREFIX + "grpc-otg-controller", Namespace: ixia.Namespace}
+	if err := r.exposeController(ctx, ixia, "1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(ctx, ingressName, &networkingv1.Ingress{}); err != nil {
+		t.Fatalf("ingress not applied - %v", err)
+	}
+
+	// Gateway API kinds are not registered with the fake client, so switch back to LoadBalancer
+	ixia.Spec.ApiEndPoint[GRPC_ENDPOINT_NAME] = networkv1beta1.IxiaTGSvcPort{In: CTRL_GRPC_PORT}
+	if err := r.exposeController(ctx, ixia, "1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(ctx, ingressName, &networkingv1.Ingress{}); !errapi.IsNotFound(err) {
+		t.Errorf("ingress of previous exposure not deleted - %v", err)
+	}
+}
+
+func TestBackendTLSPolicy(t *testing.T) {
+	r := testReconciler(t)
+	ixia := exposedNode(&networkv1beta1.IxiaTGTLS{Generate: true}, map[string]string{HTTP_ENDPOINT_NAME: EXPOSE_GATEWAY})
+	for _, caBundle := range []bool{true, false} {
+		policy, err := r.getBackendTLSPolicy(HTTP_ENDPOINT_NAME, ixia, caBundle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		host, _, _ := unstructured.NestedString(policy.Object, "spec", "validation", "hostname")
+		if host != "service-http-otg-controller.ixia-c.svc" {
+			t.Errorf("hostname = %v", host)
+		}
+		_, hasRefs, _ := unstructured.NestedSlice(policy.Object, "spec", "validation", "caCertificateRefs")
+		wellKnown, _, _ := unstructured.NestedString(policy.Object, "spec", "validation", "wellKnownCACertificates")
+		if hasRefs != caBundle || (wellKnown == WELL_KNOWN_CA_SYSTEM) == caBundle {
+			t.Errorf("caBundle %v: caCertificateRefs %v, wellKnownCACertificates %q", caBundle, hasRefs, wellKnown)
+		}
+		if len(policy.GetOwnerReferences()) != 1 {
+			t.Errorf("owner references = %v", policy.GetOwnerReferences())
+		}
+	}
+}
+
+func TestSetArg(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		add  bool
+		want []string
+	}{
+		{"replace", []string{"--debug", "--http-port", "8443"}, false, []string{"--debug", "--http-port", "9443"}},
+		{"missing", []string{"--debug"}, false, []string{"--debug"}},
+		{"missing added", []string{"--debug"}, true, []string{"--debug", "--http-port", "9443"}},
+		{"empty added", []string{}, true, []string{"--http-port", "9443"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orig := append([]string{}, tt.args...)
+			got := setArg(tt.args, CTRL_HTTP_PORT_ARG, "9443", tt.add)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("setArg() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.args, orig) {
+				t.Errorf("setArg() modified args %v, was %v", tt.args, orig)
+			}
+		})
+	}
+}
+
+func TestSetEndpointPorts(t *testing.T) {
+	standard := controllerPorts{http: CTRL_HTTPS_PORT, grpc: CTRL_GRPC_PORT, gnmi: CTRL_GNMI_PORT}
+	custom := controllerPorts{http: 9443, grpc: 9051, gnmi: 9052}
+	tests := []struct {
+		name        string
+		cont        corev1.Container
+		ports       controllerPorts
+		newGNMI     bool
+		wantArgs    []string
+		wantCommand []string
+		wantPort    int32
+	}{
+		{"controller standard", corev1.Container{Name: CONTROLLER_NAME, Args: []string{"--accept-eula"}},
+			standard, false, []string{"--accept-eula"}, nil, 0},
+		{"controller custom", corev1.Container{Name: CONTROLLER_NAME, Args: []string{"--accept-eula", "--grpc-port", "40051"}},
+			custom, false, []string{"--accept-eula", "--grpc-port", "9051", "--http-port", "9443"}, nil, 0},
+		{"controller custom without grpc arg", corev1.Container{Name: CONTROLLER_NAME, Args: []string{"--accept-eula"}},
+			custom, false, []string{"--accept-eula", "--grpc-port", "9051", "--http-port", "9443"}, nil, 0},
+		{"controller standard grpc arg", corev1.Container{Name: CONTROLLER_NAME, Args: []string{"--accept-eula", "--grpc-port", "9051"}},
+			standard, false, []string{"--accept-eula", "--grpc-port", "40051"}, nil, 0},
+		{"gnmi standard", corev1.Container{Name: GNMI_NAME, Args: []string{"-http-server", "https://localhost:8443"}, Ports: []corev1.ContainerPort{{ContainerPort: CTRL_GNMI_PORT}}},
+			standard, true, []string{"-http-server", "https://localhost:8443"}, nil, CTRL_GNMI_PORT},
+		{"gnmi custom", corev1.Container{Name: GNMI_NAME, Args: []string{"-http-server", "https://localhost:8443"}, Ports: []corev1.ContainerPort{{ContainerPort: CTRL_GNMI_PORT}}},
+			custom, true, []string{"-http-server", "https://localhost:9443", "-server-port", "9052"}, nil, 9052},
+		{"gnmi command", corev1.Container{Name: GNMI_NAME, Command: []string{"python3", "--server-port", "50051", "--target-port", "8443"}},
+			custom, false, nil, []string{"python3", "--server-port", "9052", "--target-port", "9443"}, 0},
+		{"grpc custom", corev1.Container{Name: GRPC_NAME, Command: []string{"python3", "--target-port", "8443"}, Ports: []corev1.ContainerPort{{ContainerPort: CTRL_GRPC_PORT}}},
+			custom, false, nil, []string{"python3", "--target-port", "9443", "--server-port", "9051"}, 9051},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cont := tt.cont
+			setEndpointPorts(&cont, tt.ports, tt.newGNMI)
+			if !reflect.DeepEqual(cont.Args, tt.wantArgs) || !reflect.DeepEqual(cont.Command, tt.wantCommand) {
+				t.Errorf("setEndpointPorts() args %v, command %v, want %v, %v", cont.Args, cont.Command, tt.wantArgs, tt.wantCommand)
+			}
+			if tt.wantPort != 0 && cont.Ports[0].ContainerPort != tt.wantPort {
+				t.Errorf("setEndpointPorts() container port %v, want %v", cont.Ports[0].ContainerPort, tt.wantPort)
+			}
+		})
+	}
+}
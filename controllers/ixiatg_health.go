@@ -0,0 +1,440 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	errapi "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	networkv1beta1 "github.com/open-traffic-generator/keng-operator/api/v1beta1"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DEFAULT_DEPLOY_TIMEOUT    time.Duration = 10 * time.Minute
+	DEFAULT_RESTART_THRESHOLD int32         = 3
+	TERMINATION_MSG_TAIL      int           = 512
+
+	FAILURE_POD_FAILED       string = "PodFailed"
+	FAILURE_IMAGE_PULL       string = "ImagePullFailed"
+	FAILURE_CRASH_LOOP       string = "CrashLoopBackOff"
+	FAILURE_OOM_KILLED       string = "OOMKilled"
+	FAILURE_CONTAINER_CONFIG string = "CreateContainerConfigError"
+	FAILURE_INIT_CONTAINER   string = "InitContainerFailed"
+
+	CONTAINER_STATE_RUNNING string = "Running"
+
+	CONDITION_PORT_PODS_AVAILABLE string = "PortPodsAvailable"
+	PODS_REASON_PRESENT           string = "PodsPresent"
+	PODS_REASON_MISSING           string = "PodsMissing"
+
+	REASON_ERR_IMAGE_PULL        string = "ErrImagePull"
+	REASON_IMAGE_PULL_BACK_OFF   string = "ImagePullBackOff"
+	REASON_CRASH_LOOP_BACK_OFF   string = "CrashLoopBackOff"
+	REASON_CREATE_CONFIG_ERROR   string = "CreateContainerConfigError"
+	REASON_OOM_KILLED            string = "OOMKilled"
+	MSG_REPOSITORY_NOT_EXIST     string = "repository does not exist"
+	MSG_REPOSITORY_ACCESS_DENIED string = "access to the resource is denied"
+)
+
+// deployTimeout returns the deployment deadline of the IxiaTG, spec setting overrides the operator default
+func (r *IxiaTGReconciler) deployTimeout(ixia *networkv1beta1.IxiaTG) time.Duration {
+	if ixia.Spec.DeployTimeout != nil && ixia.Spec.DeployTimeout.Duration > 0 {
+		return ixia.Spec.DeployTimeout.Duration
+	}
+	if r.DeployTimeout > 0 {
+		return r.DeployTimeout
+	}
+	return DEFAULT_DEPLOY_TIMEOUT
+}
+
+func validateDeployTimeout(ixia *networkv1beta1.IxiaTG) error {
+	if ixia.Spec.DeployTimeout != nil && ixia.Spec.DeployTimeout.Duration < 0 {
+		return errors.New(fmt.Sprintf("Invalid deploy timeout %v", ixia.Spec.DeployTimeout.Duration))
+	}
+	return nil
+}
+
+func (r *IxiaTGReconciler) restartThreshold() int32 {
+	if r.RestartThreshold > 0 {
+		return r.RestartThreshold
+	}
+	return DEFAULT_RESTART_THRESHOLD
+}
+
+// terminationMessageTail returns the tail of the last termination message of a container
+func terminationMessageTail(s *corev1.ContainerStatus) string {
+	term := s.State.Terminated
+	if term == nil {
+		term = s.LastTerminationState.Terminated
+	}
+	if term == nil {
+		return ""
+	}
+	msg := strings.TrimSpace(term.Message)
+	if len(msg) > TERMINATION_MSG_TAIL {
+		msg = "..." + msg[len(msg)-TERMINATION_MSG_TAIL:]
+	}
+	return msg
+}
+
+// containerFailure classifies a container state which will not recover by itself; empty if none
+func containerFailure(s *corev1.ContainerStatus, init bool, restartThreshold int32) (string, string) {
+	failure := ""
+	detail := ""
+	if s.State.Waiting != nil {
+		msg := s.State.Waiting.Message
+		switch s.State.Waiting.Reason {
+		case REASON_ERR_IMAGE_PULL:
+			if strings.Contains(msg, MSG_REPOSITORY_NOT_EXIST) || strings.Contains(msg, MSG_REPOSITORY_ACCESS_DENIED) {
+				failure, detail = FAILURE_IMAGE_PULL, msg
+			}
+		case REASON_IMAGE_PULL_BACK_OFF:
+			failure, detail = FAILURE_IMAGE_PULL, msg
+		case REASON_CREATE_CONFIG_ERROR:
+			failure, detail = FAILURE_CONTAINER_CONFIG, msg
+		case REASON_CRASH_LOOP_BACK_OFF:
+			if s.RestartCount >= restartThreshold {
+				failure, detail = FAILURE_CRASH_LOOP, fmt.Sprintf("restarted %d times", s.RestartCount)
+			}
+		}
+	}
+	if failure == "" || failure == FAILURE_CRASH_LOOP {
+		for _, term := range []*corev1.ContainerStateTerminated{s.State.Terminated, s.LastTerminationState.Terminated} {
+			if term != nil && term.Reason == REASON_OOM_KILLED {
+				failure, detail = FAILURE_OOM_KILLED, fmt.Sprintf("restarted %d times", s.RestartCount)
+				break
+			}
+		}
+	}
+	if init && failure == "" && s.State.Terminated != nil && s.State.Terminated.ExitCode != 0 && s.RestartCount >= restartThreshold {
+		failure, detail = FAILURE_INIT_CONTAINER, fmt.Sprintf("exit code %d after %d restarts", s.State.Terminated.ExitCode, s.RestartCount)
+	}
+	if failure == "" {
+		return "", ""
+	}
+	if init && failure == FAILURE_CRASH_LOOP {
+		failure = FAILURE_INIT_CONTAINER
+	}
+	if tail := terminationMessageTail(s); tail != "" && failure != FAILURE_IMAGE_PULL && failure != FAILURE_CONTAINER_CONFIG {
+		detail += ", last termination: " + tail
+	}
+	return failure, detail
+}
+
+// podFailure returns the classified failure of a pod which will not become ready by itself; unschedulable
+// pods are reported by podBlockingReason at the deployment deadline since the cluster may still scale up
+func podFailure(pod *corev1.Pod, restartThreshold int32) error {
+	if pod.Status.Phase == corev1.PodFailed {
+		return errors.New(fmt.Sprintf("Pod %s failed - %s: %s", pod.Name, FAILURE_POD_FAILED, pod.Status.Reason))
+	}
+	for i, s := range pod.Status.InitContainerStatuses {
+		if failure, detail := containerFailure(&pod.Status.InitContainerStatuses[i], true, restartThreshold); failure != "" {
+			return errors.New(fmt.Sprintf("Pod %s init container %s failed - %s: %s", pod.Name, s.Name, failure, detail))
+		}
+	}
+	for i, s := range pod.Status.ContainerStatuses {
+		if failure, detail := containerFailure(&pod.Status.ContainerStatuses[i], false, restartThreshold); failure != "" {
+			return errors.New(fmt.Sprintf("Pod %s container %s failed - %s: %s", pod.Name, s.Name, failure, detail))
+		}
+	}
+	return nil
+}
+
+// podBlockingReason summarizes why a pod is not yet ready; empty if the pod is ready
+func podBlockingReason(pod *corev1.Pod) string {
+	reasons := []string{}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", c.Reason, c.Message))
+		}
+	}
+	statuses := append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...)
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+	for _, s := range statuses {
+		if s.Ready {
+			continue
+		}
+		if s.State.Waiting != nil && s.State.Waiting.Reason != "" {
+			reason := fmt.Sprintf("container %s %s", s.Name, s.State.Waiting.Reason)
+			if s.State.Waiting.Message != "" {
+				reason += ": " + s.State.Waiting.Message
+			}
+			reasons = append(reasons, reason)
+		} else if s.State.Terminated != nil && s.State.Terminated.ExitCode != 0 {
+			reasons = append(reasons, fmt.Sprintf("container %s %s (exit code %d)", s.Name, s.State.Terminated.Reason, s.State.Terminated.ExitCode))
+		} else if s.State.Running != nil {
+			reasons = append(reasons, fmt.Sprintf("container %s not ready", s.Name))
+		}
+	}
+	if len(reasons) == 0 && pod.Status.Phase != corev1.PodRunning {
+		reasons = append(reasons, fmt.Sprintf("phase %s", pod.Status.Phase))
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// deployTimeoutError returns the failure after the deployment deadline, summarizing the pods blocking it
+func deployTimeoutError(timeout time.Duration, start metav1.Time, pods []corev1.Pod) error {
+	if start.IsZero() || time.Since(start.Time) < timeout {
+		return nil
+	}
+	blocked := []string{}
+	for i := range pods {
+		if reason := podBlockingReason(&pods[i]); reason != "" {
+			blocked = append(blocked, fmt.Sprintf("pod %s (%s)", pods[i].Name, reason))
+		}
+	}
+	if len(blocked) == 0 {
+		blocked = append(blocked, "controller rollout not complete")
+	}
+	return errors.New(fmt.Sprintf("Deployment not ready within %v - %s", timeout, strings.Join(blocked, "; ")))
+}
+
+func containerState(s *corev1.ContainerStatus) string {
+	if s.State.Waiting != nil {
+		return s.State.Waiting.Reason
+	}
+	if s.State.Terminated != nil {
+		return s.State.Terminated.Reason
+	}
+	if s.State.Running != nil {
+		return CONTAINER_STATE_RUNNING
+	}
+	return ""
+}
+
+// podsStatus builds the status of generated pods, sorted by pod name; a pod listed twice is reported once
+func podsStatus(pods []corev1.Pod) []networkv1beta1.IxiaTGPodStatus {
+	statuses := []networkv1beta1.IxiaTGPodStatus{}
+	seen := map[string]bool{}
+	for _, p := range pods {
+		if seen[p.Name] {
+			continue
+		}
+		seen[p.Name] = true
+		podStatus := networkv1beta1.IxiaTGPodStatus{
+			Name:  p.Name,
+			Phase: string(p.Status.Phase),
+			Node:  p.Spec.NodeName,
+			PodIP: p.Status.PodIP,
+		}
+		for i, s := range p.Status.ContainerStatuses {
+			podStatus.Restarts += s.RestartCount
+			podStatus.Containers = append(podStatus.Containers, networkv1beta1.IxiaTGContainerStatus{
+				Name:         s.Name,
+				Image:        s.Image,
+				Ready:        s.Ready,
+				RestartCount: s.RestartCount,
+				State:        containerState(&p.Status.ContainerStatuses[i]),
+			})
+		}
+		statuses = append(statuses, podStatus)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// updatePodsStatus sets the status of generated pods; returns whether it changed
+func updatePodsStatus(ixia *networkv1beta1.IxiaTG, pods []corev1.Pod) bool {
+	statuses := podsStatus(pods)
+	if equality.Semantic.DeepEqual(ixia.Status.Pods, statuses) {
+		return false
+	}
+	ixia.Status.Pods = statuses
+	return true
+}
+
+// getGeneratedPods returns the live controller and port pods of the IxiaTG
+func (r *IxiaTGReconciler) getGeneratedPods(ctx context.Context, ixia *networkv1beta1.IxiaTG) ([]corev1.Pod, error) {
+	podList := &corev1.PodList{}
+	opts := []client.ListOption{
+		client.InNamespace(ixia.Namespace),
+		client.MatchingLabels{"app": ixia.Name + CTRL_POD_NAME_SUFFIX},
+	}
+	if err := r.List(ctx, podList, opts...); err != nil {
+		return nil, err
+	}
+	pods := []corev1.Pod{}
+	for _, p := range podList.Items {
+		if p.Status.Phase == corev1.PodFailed || !p.DeletionTimestamp.IsZero() {
+			continue
+		}
+		pods = append(pods, p)
+	}
+
+	seen := map[string]bool{}
+	for _, intf := range ixia.Status.Interfaces {
+		if seen[intf.PodName] {
+			continue
+		}
+		seen[intf.PodName] = true
+		pod := corev1.Pod{}
+		if err := r.getGenerated(ctx, types.NamespacedName{Name: intf.PodName, Namespace: ixia.Namespace}, &pod); err != nil {
+			if errapi.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		if !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+		pods = append(pods, pod)
+	}
+	return pods, nil
+}
+
+// renderHash returns the hash of the inputs the generated objects of a node are rendered from: its spec
+// generation, the cached data of its release and the versions of the operator secrets replicated into its
+// namespace; only cached objects are read. TLS secrets are not cached, so for nodes with TLS the hash also
+// changes every TLS_RECHECK_INTERVAL
+func (r *IxiaTGReconciler) renderHash(ctx context.Context, ixia *networkv1beta1.IxiaTG) (string, error) {
+	release := getLatestVersion()
+	if ixia.Spec.Release != "" && ixia.Spec.Release != DEFAULT_VERSION {
+		release = ixia.Spec.Release
+	}
+	data, err := json.Marshal(relDep(release))
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%d/%s/", ixia.Generation, release)
+	h.Write(data)
+	for _, name := range r.replicatedSecrets() {
+		secret := &corev1.Secret{}
+		err = r.Get(ctx, types.NamespacedName{Name: name, Namespace: CURRENT_NAMESPACE}, secret)
+		if err != nil && !errapi.IsNotFound(err) {
+			return "", err
+		}
+		fmt.Fprintf(h, "/%s=%s", name, secret.ResourceVersion)
+	}
+	if ixia.Spec.TLS != nil {
+		fmt.Fprintf(h, "/tls=%d", time.Now().Truncate(TLS_RECHECK_INTERVAL).Unix())
+	}
+	return hex.EncodeToString(h.Sum(nil))[:SPEC_HASH_LENGTH], nil
+}
+
+// missingPortPods returns the port pods of a node not present, or being deleted, sorted by name
+func missingPortPods(ixia *networkv1beta1.IxiaTG, pods []corev1.Pod) []string {
+	present := map[string]bool{}
+	for _, p := range pods {
+		present[p.Name] = true
+	}
+	missing := []string{}
+	for _, intf := range ixia.Status.Interfaces {
+		if !present[intf.PodName] && !containsString(missing, intf.PodName) {
+			missing = append(missing, intf.PodName)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// setPortPodsCondition reports port pods missing from a deployed node, e.g. deleted for recreation
+func setPortPodsCondition(ixia *networkv1beta1.IxiaTG, missing []string) bool {
+	cond := metav1.Condition{
+		Type:               CONDITION_PORT_PODS_AVAILABLE,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: ixia.Generation,
+		Reason:             PODS_REASON_PRESENT,
+		Message:            "All port pods present",
+	}
+	if len(missing) > 0 {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = PODS_REASON_MISSING
+		cond.Message = fmt.Sprintf("Port pods %s missing, being recreated", strings.Join(missing, ", "))
+	}
+	return meta.SetStatusCondition(&ixia.Status.Conditions, cond)
+}
+
+// reconcileDeployed keeps a deployed IxiaTG in line with its spec and refreshes the status of its pods;
+// the node is rendered again, re-syncing secrets and re-applying objects drifted from their spec hash with
+// auto update policy, only once its render hash changes or port pods are missing, which are recreated
+// and reported through the PortPodsAvailable condition
+func (r *IxiaTGReconciler) reconcileDeployed(ctx context.Context, req ctrl.Request, ixia *networkv1beta1.IxiaTG) (ctrl.Result, error) {
+	pods, err := r.getGeneratedPods(ctx, ixia)
+	if err != nil {
+		log.Errorf("Failed to get pods of %v in %v - %v", ixia.Name, ixia.Namespace, err)
+		return ctrl.Result{}, err
+	}
+	missing := missingPortPods(ixia, pods)
+	hash, err := r.renderHash(ctx, ixia)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	changed := false
+	if hash != ixia.Status.RenderHash || len(missing) > 0 {
+		log.Infof("Rendering %v (render hash %v, found %v, missing pods %v)", ixia.Name, hash, ixia.Status.RenderHash, missing)
+		if err = r.ReconcileSecrets(ctx, req, ixia); err != nil {
+			log.Errorf("Failed to sync secrets of %v in %v - %v", ixia.Name, ixia.Namespace, err)
+			return ctrl.Result{}, err
+		}
+		// Drifted objects are repaired as per update policy, see applyGenerated
+		if err = r.deployNode(ctx, ixia); err != nil {
+			return ctrl.Result{}, err
+		}
+		// License secret changes are reported through the condition without failing the deployed node
+		licChanged, err := r.reconcileLicense(ctx, ixia)
+		if err != nil {
+			log.Errorf("Invalid license configuration of %v - %v", ixia.Name, err)
+		}
+		changed = licChanged
+		// Release data loaded while rendering is part of the hash
+		if hash, err = r.renderHash(ctx, ixia); err != nil {
+			return ctrl.Result{}, err
+		}
+		if ixia.Status.RenderHash != hash {
+			ixia.Status.RenderHash = hash
+			changed = true
+		}
+	}
+	changed = updatePodsStatus(ixia, pods) || changed
+	changed = setPortPodsCondition(ixia, missing) || changed
+	// Nodes deployed before license pools were configured hold a seat from then on
+	if pool := r.licensePool(ixia); pool != "" && ixia.Status.LicenseSeat != pool {
+		ixia.Status.LicenseSeat = pool
+		changed = true
+	}
+	if changed {
+		if err = r.Status().Update(ctx, ixia); err != nil {
+			log.Errorf("Failed to update ixia status - %v", err)
+			return ctrl.Result{}, err
+		}
+	}
+	if ixia.Spec.TLS != nil {
+		return ctrl.Result{RequeueAfter: time.Until(time.Now().Truncate(TLS_RECHECK_INTERVAL).Add(TLS_RECHECK_INTERVAL))}, nil
+	}
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,405 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	networkv1beta1 "github.com/open-traffic-generator/keng-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestContainerFailure(t *testing.T) {
+	waiting := func(reason string, msg string) corev1.ContainerState {
+		return corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason, Message: msg}}
+	}
+	terminated := func(reason string, code int32, msg string) *corev1.ContainerStateTerminated {
+		return &corev1.ContainerStateTerminated{Reason: reason, ExitCode: code, Message: msg}
+	}
+	tests := []struct {
+		name       string
+		status     corev1.ContainerStatus
+		init       bool
+		wantFail   string
+		wantDetail string
+	}{
+		{"running", corev1.ContainerStatus{State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}}, false, "", ""},
+		{"transient pull error", corev1.ContainerStatus{State: waiting(REASON_ERR_IMAGE_PULL, "i/o timeout")}, false, "", ""},
+		{"missing repository", corev1.ContainerStatus{State: waiting(REASON_ERR_IMAGE_PULL, "pull failed: "+MSG_REPOSITORY_NOT_EXIST)}, false, FAILURE_IMAGE_PULL, "pull failed: " + MSG_REPOSITORY_NOT_EXIST},
+		{"pull back off", corev1.ContainerStatus{State: waiting(REASON_IMAGE_PULL_BACK_OFF, "back-off")}, false, FAILURE_IMAGE_PULL, "back-off"},
+		{"config error", corev1.ContainerStatus{State: waiting(REASON_CREATE_CONFIG_ERROR, "secret not found")}, false, FAILURE_CONTAINER_CONFIG, "secret not found"},
+		{"crash loop below threshold", corev1.ContainerStatus{State: waiting(REASON_CRASH_LOOP_BACK_OFF, ""), RestartCount: 2}, false, "", ""},
+		{
+			"crash loop", corev1.ContainerStatus{
+				State: waiting(REASON_CRASH_LOOP_BACK_OFF, ""), RestartCount: 3,
+				LastTerminationState: corev1.ContainerState{Terminated: terminated("Error", 1, " license not found \n")},
+			}, false, FAILURE_CRASH_LOOP, "restarted 3 times, last termination: license not found",
+		},
+		{
+			"oom killed", corev1.ContainerStatus{
+				State: waiting(REASON_CRASH_LOOP_BACK_OFF, ""), RestartCount: 1,
+				LastTerminationState: corev1.ContainerState{Terminated: terminated(REASON_OOM_KILLED, 137, "")},
+			}, false, FAILURE_OOM_KILLED, "restarted 1 times",
+		},
+		{
+			"init container exit", corev1.ContainerStatus{
+				State: corev1.ContainerState{Terminated: terminated("Error", 2, "")}, RestartCount: 3,
+			}, true, FAILURE_INIT_CONTAINER, "exit code 2 after 3 restarts",
+		},
+		{
+			"init container crash loop", corev1.ContainerStatus{
+				State: waiting(REASON_CRASH_LOOP_BACK_OFF, ""), RestartCount: 4,
+			}, true, FAILURE_INIT_CONTAINER, "restarted 4 times",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failure, detail := containerFailure(&tt.status, tt.init, DEFAULT_RESTART_THRESHOLD)
+			if failure != tt.wantFail || detail != tt.wantDetail {
+				t.Errorf("containerFailure() = %q, %q, want %q, %q", failure, detail, tt.wantFail, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestPodFailure(t *testing.T) {
+	pullBackOff := corev1.ContainerStatus{
+		Name:  "init-container",
+		State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: REASON_IMAGE_PULL_BACK_OFF}},
+	}
+	tests := []struct {
+		name    string
+		pod     corev1.Pod
+		wantErr string
+	}{
+		{"pending", corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodPending}}, ""},
+		{"evicted", corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodFailed, Reason: "Evicted"}}, FAILURE_POD_FAILED + ": Evicted"},
+		{"init container", corev1.Pod{Status: corev1.PodStatus{InitContainerStatuses: []corev1.ContainerStatus{pullBackOff}}}, "init container init-container failed - " + FAILURE_IMAGE_PULL},
+		{"container", corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{pullBackOff}}}, "container init-container failed - " + FAILURE_IMAGE_PULL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.pod.ObjectMeta = metav1.ObjectMeta{Name: "otg-port-eth1"}
+			err := podFailure(&tt.pod, DEFAULT_RESTART_THRESHOLD)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("podFailure() error = %v, want none", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("podFailure() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPodBlockingReason(t *testing.T) {
+	tests := []struct {
+		name   string
+		status corev1.PodStatus
+		want   string
+	}{
+		{"ready", corev1.PodStatus{
+			Phase:             corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "otg-port-eth1-protocol-engine", Ready: true}},
+		}, ""},
+		{"no status yet", corev1.PodStatus{Phase: corev1.PodPending}, "phase Pending"},
+		{"unschedulable", corev1.PodStatus{
+			Phase: corev1.PodPending,
+			Conditions: []corev1.PodCondition{{
+				Type: corev1.PodScheduled, Status: corev1.ConditionFalse,
+				Reason: corev1.PodReasonUnschedulable, Message: "0/3 nodes are available: 3 Insufficient memory.",
+			}},
+		}, "Unschedulable: 0/3 nodes are available: 3 Insufficient memory."},
+		{"image pull back off", corev1.PodStatus{
+			Phase: corev1.PodPending,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "otg-port-eth1-traffic-engine",
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: REASON_IMAGE_PULL_BACK_OFF, Message: "Back-off pulling image"}},
+			}},
+		}, "container otg-port-eth1-traffic-engine " + REASON_IMAGE_PULL_BACK_OFF + ": Back-off pulling image"},
+		{"crash loop back off", corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:         "otg-port-eth1-protocol-engine",
+				RestartCount: 5,
+				State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: REASON_CRASH_LOOP_BACK_OFF}},
+			}},
+		}, "container otg-port-eth1-protocol-engine " + REASON_CRASH_LOOP_BACK_OFF},
+		{"init container exit", corev1.PodStatus{
+			Phase: corev1.PodPending,
+			InitContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "init-container",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 1}},
+			}},
+		}, "container init-container Error (exit code 1)"},
+		{"not ready", corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "keng-controller",
+				State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			}},
+		}, "container keng-controller not ready"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := corev1.Pod{Status: tt.status}
+			if got := podBlockingReason(&pod); got != tt.want {
+				t.Errorf("podBlockingReason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrashLoopThreshold(t *testing.T) {
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "otg-port-eth1"},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			Name:         "otg-port-eth1-protocol-engine",
+			RestartCount: 4,
+			State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: REASON_CRASH_LOOP_BACK_OFF}},
+		}}},
+	}
+	tests := []struct {
+		name      string
+		threshold int32
+		wantErr   bool
+	}{
+		{"below threshold", 5, false},
+		{"at threshold", 4, true},
+		{"past threshold", DEFAULT_RESTART_THRESHOLD, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := podFailure(&pod, tt.threshold)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("podFailure() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), FAILURE_CRASH_LOOP) {
+				t.Errorf("podFailure() error = %v, want %q", err, FAILURE_CRASH_LOOP)
+			}
+		})
+	}
+}
+
+func TestDeployTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     *metav1.Duration
+		operator time.Duration
+		want     time.Duration
+	}{
+		{"default", nil, 0, DEFAULT_DEPLOY_TIMEOUT},
+		{"operator default", nil, 2 * time.Minute, 2 * time.Minute},
+		{"node override", &metav1.Duration{Duration: 30 * time.Second}, 2 * time.Minute, 30 * time.Second},
+		{"zero node override", &metav1.Duration{}, 2 * time.Minute, 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := IxiaTGReconciler{DeployTimeout: tt.operator}
+			ixia := networkv1beta1.IxiaTG{Spec: networkv1beta1.IxiaTGSpec{DeployTimeout: tt.spec}}
+			if got := r.deployTimeout(&ixia); got != tt.want {
+				t.Errorf("deployTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeployTimeoutError(t *testing.T) {
+	pending := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "otg-port-eth1"},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			Conditions: []corev1.PodCondition{{
+				Type: corev1.PodScheduled, Status: corev1.ConditionFalse,
+				Reason: corev1.PodReasonUnschedulable, Message: "no nodes",
+			}},
+		},
+	}
+	ready := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "otg-controller"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	started := metav1.NewTime(time.Now().Add(-time.Minute))
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		start   metav1.Time
+		pods    []corev1.Pod
+		wantErr string
+	}{
+		{"not started", time.Second, metav1.Time{}, []corev1.Pod{pending}, ""},
+		{"before deadline", 2 * time.Minute, started, []corev1.Pod{pending}, ""},
+		{"blocked pod", 30 * time.Second, started, []corev1.Pod{ready, pending}, "Deployment not ready within 30s - pod otg-port-eth1 (Unschedulable: no nodes)"},
+		{"rollout", 30 * time.Second, started, []corev1.Pod{ready}, "controller rollout not complete"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := deployTimeoutError(tt.timeout, tt.start, tt.pods)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("deployTimeoutError() error = %v, want none", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("deployTimeoutError() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPodsStatus(t *testing.T) {
+	pods := []corev1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "otg-port-eth2"},
+			Spec:       corev1.PodSpec{NodeName: "worker-1"},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodPending,
+				PodIP: "10.244.0.8",
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:         "otg-port-eth2-traffic-engine",
+					Image:        "ghcr.io/open-traffic-generator/ixia-c-traffic-engine:1.6.0.100",
+					RestartCount: 2,
+					State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: REASON_IMAGE_PULL_BACK_OFF}},
+				}, {
+					Name:         "otg-port-eth2-protocol-engine",
+					RestartCount: 1,
+					State:        corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 1}},
+				}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "otg-port-eth1"},
+			Spec:       corev1.PodSpec{NodeName: "worker-0"},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				PodIP: "10.244.0.7",
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:  "otg-port-eth1-traffic-engine",
+					Ready: true,
+					State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				}},
+			},
+		},
+		{ObjectMeta: metav1.ObjectMeta{Name: "otg-port-eth3"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "otg-port-eth1"}},
+	}
+	want := []networkv1beta1.IxiaTGPodStatus{
+		{
+			Name: "otg-port-eth1", Phase: "Running", Node: "worker-0", PodIP: "10.244.0.7",
+			Containers: []networkv1beta1.IxiaTGContainerStatus{
+				{Name: "otg-port-eth1-traffic-engine", Ready: true, State: CONTAINER_STATE_RUNNING},
+			},
+		},
+		{
+			Name: "otg-port-eth2", Phase: "Pending", Node: "worker-1", PodIP: "10.244.0.8", Restarts: 3,
+			Containers: []networkv1beta1.IxiaTGContainerStatus{
+				{
+					Name: "otg-port-eth2-traffic-engine", Image: "ghcr.io/open-traffic-generator/ixia-c-traffic-engine:1.6.0.100",
+					RestartCount: 2, State: REASON_IMAGE_PULL_BACK_OFF,
+				},
+				{Name: "otg-port-eth2-protocol-engine", RestartCount: 1, State: "Error"},
+			},
+		},
+		{Name: "otg-port-eth3"},
+	}
+	if got := podsStatus(pods); !reflect.DeepEqual(got, want) {
+		t.Errorf("podsStatus() = %+v, want %+v", got, want)
+	}
+}
+
+func TestReconcileDeployed(t *testing.T) {
+	tests := []struct {
+		name        string
+		podPresent  bool
+		staleHash   bool
+		wantRender  bool
+		wantMissing bool
+	}{
+		{"unchanged", true, false, false, false},
+		{"render hash changed", true, true, true, false},
+		{"pod missing", false, false, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ixia, deploy := testDeployingNode("test-reconcile-deployed")
+			ixia.Status.State = STATE_DEPLOYED
+			objs := []client.Object{ixia, deploy}
+			if tt.podPresent {
+				objs = append(objs, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "otg-port-eth1", Namespace: ixia.Namespace}})
+			}
+			applied := 0
+			funcs := interceptor.Funcs{
+				Apply: func(ctx context.Context, c client.WithWatch, obj runtime.ApplyConfiguration, opts ...client.ApplyOption) error {
+					applied++
+					return c.Apply(ctx, obj, opts...)
+				},
+			}
+			r := testReconcilerWithFuncs(t, funcs, objs...)
+			testRelease(t, r, ixia.Spec.Release)
+
+			ctx := context.Background()
+			if err := r.Get(ctx, client.ObjectKeyFromObject(ixia), ixia); err != nil {
+				t.Fatal(err)
+			}
+			hash, err := r.renderHash(ctx, ixia)
+			if err != nil {
+				t.Fatalf("renderHash() error = %v", err)
+			}
+			ixia.Status.RenderHash = hash
+			if tt.staleHash {
+				ixia.Status.RenderHash = "0123"
+			}
+			if err = r.Status().Update(ctx, ixia); err != nil {
+				t.Fatal(err)
+			}
+
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ixia)}
+			if _, err = r.reconcileDeployed(ctx, req, ixia); err != nil {
+				t.Fatalf("reconcileDeployed() error = %v", err)
+			}
+			if (applied > 0) != tt.wantRender {
+				t.Errorf("reconcileDeployed() applied %d objects, want render %v", applied, tt.wantRender)
+			}
+			got := &networkv1beta1.IxiaTG{}
+			if err = r.Get(ctx, req.NamespacedName, got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Status.State != STATE_DEPLOYED {
+				t.Errorf("state = %v, want %v", got.Status.State, STATE_DEPLOYED)
+			}
+			if got.Status.RenderHash != hash {
+				t.Errorf("render hash = %v, want %v", got.Status.RenderHash, hash)
+			}
+			cond := meta.FindStatusCondition(got.Status.Conditions, CONDITION_PORT_PODS_AVAILABLE)
+			if cond == nil || (cond.Status == metav1.ConditionFalse) != tt.wantMissing {
+				t.Errorf("%s condition = %+v, want missing %v", CONDITION_PORT_PODS_AVAILABLE, cond, tt.wantMissing)
+			}
+			if tt.wantMissing && !strings.Contains(cond.Message, "otg-port-eth1") {
+				t.Errorf("%s condition message = %v, want missing pod", CONDITION_PORT_PODS_AVAILABLE, cond.Message)
+			}
+		})
+	}
+}
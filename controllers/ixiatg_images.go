@@ -0,0 +1,137 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	networkv1beta1 "github.com/open-traffic-generator/keng-operator/api/v1beta1"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DEFAULT_INIT_IMAGE  string            = "networkop/init-wait:latest"
+	DEFAULT_PULL_POLICY corev1.PullPolicy = corev1.PullIfNotPresent
+	ALL_COMPONENTS      string            = "*"
+	DIGEST_ALGO_PREFIX  string            = "sha256:"
+)
+
+// RegistryRewrite replaces the image reference prefix From with To
+type RegistryRewrite struct {
+	From string
+	To   string
+}
+
+// ParseRegistryRewrites parses comma separated <from>=<to> registry rewrite rules
+func ParseRegistryRewrites(rules string) ([]RegistryRewrite, error) {
+	rewrites := []RegistryRewrite{}
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		parts := strings.SplitN(rule, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.New(fmt.Sprintf("Invalid registry rewrite rule %s; expected <from>=<to>", rule))
+		}
+		rewrites = append(rewrites, RegistryRewrite{From: parts[0], To: parts[1]})
+	}
+	return rewrites, nil
+}
+
+// imageName builds the image reference for a component, applying the first matching registry rewrite rule;
+// digest, when specified, pins the image and takes precedence over tag
+func (r *IxiaTGReconciler) imageName(path string, tag string, digest string) string {
+	image := path
+	if digest != "" {
+		if !strings.Contains(digest, ":") {
+			digest = DIGEST_ALGO_PREFIX + digest
+		}
+		image += "@" + digest
+	} else if tag != "" {
+		image += ":" + tag
+	}
+	for _, rule := range r.RegistryRewrites {
+		if strings.HasPrefix(image, rule.From) {
+			rewritten := rule.To + strings.TrimPrefix(image, rule.From)
+			log.Infof("Rewriting image %s to %s", image, rewritten)
+			return rewritten
+		}
+	}
+	return image
+}
+
+// imagePullSecrets returns the pull secrets replicated by the operator followed by the ones in spec
+func (r *IxiaTGReconciler) imagePullSecrets(ixia *networkv1beta1.IxiaTG) []corev1.LocalObjectReference {
+	var secrets []corev1.LocalObjectReference
+	names := append([]string{}, r.ImagePullSecrets...)
+	names = append(names, ixia.Spec.ImagePullSecrets...)
+	for _, name := range names {
+		dup := false
+		for _, s := range secrets {
+			if s.Name == name {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			secrets = append(secrets, corev1.LocalObjectReference{Name: name})
+		}
+	}
+	return secrets
+}
+
+func validPullPolicy(policy string) bool {
+	switch corev1.PullPolicy(policy) {
+	case corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever:
+		return true
+	}
+	return false
+}
+
+// validatePullPolicies verifies the image pull policies specified in spec
+func validatePullPolicies(ixia *networkv1beta1.IxiaTG) error {
+	for name, policy := range ixia.Spec.ImagePullPolicy {
+		if !validPullPolicy(policy) {
+			return errors.New(fmt.Sprintf("Unsupported image pull policy %s for component %s", policy, name))
+		}
+	}
+	return nil
+}
+
+// pullPolicy determines the image pull policy of a component; spec entry for the component takes
+// precedence over spec entry for all components, followed by the release configmap entry
+func pullPolicy(ixia *networkv1beta1.IxiaTG, comp componentRel) corev1.PullPolicy {
+	if policy, ok := ixia.Spec.ImagePullPolicy[comp.Name]; ok {
+		return corev1.PullPolicy(policy)
+	}
+	if policy, ok := ixia.Spec.ImagePullPolicy[ALL_COMPONENTS]; ok {
+		return corev1.PullPolicy(policy)
+	}
+	if comp.PullPolicy != "" {
+		if validPullPolicy(comp.PullPolicy) {
+			return corev1.PullPolicy(comp.PullPolicy)
+		}
+		log.Errorf("Ignoring unsupported image pull policy %s for component %s", comp.PullPolicy, comp.Name)
+	}
+	return DEFAULT_PULL_POLICY
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	networkv1beta1 "github.com/open-traffic-generator/keng-operator/api/v1beta1"
+)
+
+func TestParseRegistryRewrites(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   string
+		want    []RegistryRewrite
+		wantErr bool
+	}{
+		{"empty", "", []RegistryRewrite{}, false},
+		{"single", "ghcr.io/otg/=registry.lab/keng/", []RegistryRewrite{{From: "ghcr.io/otg/", To: "registry.lab/keng/"}}, false},
+		{"multiple with spaces", " ghcr.io/=mirror/ , docker.io/=hub/,", []RegistryRewrite{{From: "ghcr.io/", To: "mirror/"}, {From: "docker.io/", To: "hub/"}}, false},
+		{"strip prefix", "ghcr.io/=", []RegistryRewrite{{From: "ghcr.io/", To: ""}}, false},
+		{"missing separator", "ghcr.io/", nil, true},
+		{"missing from", "=mirror/", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRegistryRewrites(tt.rules)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRegistryRewrites() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRegistryRewrites() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImageName(t *testing.T) {
+	rewrites := []RegistryRewrite{
+		{From: "ghcr.io/open-traffic-generator/", To: "registry.lab/keng/"},
+		{From: "ghcr.io/", To: "mirror.lab/"},
+	}
+	tests := []struct {
+		name     string
+		rewrites []RegistryRewrite
+		path     string
+		tag      string
+		digest   string
+		want     string
+	}{
+		{"tag", nil, "ghcr.io/open-traffic-generator/keng-controller", "1.3.0", "", "ghcr.io/open-traffic-generator/keng-controller:1.3.0"},
+		{"no tag", nil, "networkop/init-wait:latest", "", "", "networkop/init-wait:latest"},
+		{"digest over tag", nil, "ghcr.io/otg/te", "1.0", "sha256:abc", "ghcr.io/otg/te@sha256:abc"},
+		{"bare digest", nil, "ghcr.io/otg/te", "", "abc", "ghcr.io/otg/te@sha256:abc"},
+		{"first matching rewrite", rewrites, "ghcr.io/open-traffic-generator/keng-controller", "1.3.0", "", "registry.lab/keng/keng-controller:1.3.0"},
+		{"fallback rewrite", rewrites, "ghcr.io/other/te", "", "sha256:abc", "mirror.lab/other/te@sha256:abc"},
+		{"no matching rewrite", rewrites, "docker.io/library/busybox", "1", "", "docker.io/library/busybox:1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &IxiaTGReconciler{RegistryRewrites: tt.rewrites}
+			if got := r.imageName(tt.path, tt.tag, tt.digest); got != tt.want {
+				t.Errorf("imageName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPullPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     map[string]string
+		compName string
+		release  string
+		want     corev1.PullPolicy
+	}{
+		{"default", nil, "controller", "", corev1.PullIfNotPresent},
+		{"release", nil, "controller", "Always", corev1.PullAlways},
+		{"unsupported release", nil, "controller", "Sometimes", corev1.PullIfNotPresent},
+		{"all over release", map[string]string{"*": "Never"}, "controller", "Always", corev1.PullNever},
+		{"component over all", map[string]string{"*": "Never", "controller": "Always"}, "controller", "", corev1.PullAlways},
+		{"other component", map[string]string{"traffic-engine": "Never"}, "controller", "Always", corev1.PullAlways},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ixia := &networkv1beta1.IxiaTG{Spec: networkv1beta1.IxiaTGSpec{ImagePullPolicy: tt.spec}}
+			if got := pullPolicy(ixia, componentRel{Name: tt.compName, PullPolicy: tt.release}); got != tt.want {
+				t.Errorf("pullPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	errapi "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	networkv1beta1 "github.com/open-traffic-generator/keng-operator/api/v1beta1"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func validateIPFamilies(ixia *networkv1beta1.IxiaTG) error {
+	policy := corev1.IPFamilyPolicy(ixia.Spec.IPFamilyPolicy)
+	switch policy {
+	case "", corev1.IPFamilyPolicySingleStack, corev1.IPFamilyPolicyPreferDualStack, corev1.IPFamilyPolicyRequireDualStack:
+	default:
+		return errors.New(fmt.Sprintf("Unsupported IP family policy %s; expected %s, %s or %s", policy,
+			corev1.IPFamilyPolicySingleStack, corev1.IPFamilyPolicyPreferDualStack, corev1.IPFamilyPolicyRequireDualStack))
+	}
+	families := ixia.Spec.IPFamilies
+	for index, family := range families {
+		switch corev1.IPFamily(family) {
+		case corev1.IPv4Protocol, corev1.IPv6Protocol:
+		default:
+			return errors.New(fmt.Sprintf("Unsupported IP family %s; expected %s or %s", family, corev1.IPv4Protocol, corev1.IPv6Protocol))
+		}
+		if index > 0 && families[0] == family {
+			return errors.New(fmt.Sprintf("IP family %s specified more than once", family))
+		}
+	}
+	if len(families) > 2 {
+		return errors.New("At most two IP families can be specified")
+	} else if len(families) == 2 && policy == corev1.IPFamilyPolicySingleStack {
+		return errors.New(fmt.Sprintf("Two IP families require %s or %s IP family policy", corev1.IPFamilyPolicyPreferDualStack, corev1.IPFamilyPolicyRequireDualStack))
+	}
+	return nil
+}
+
+// setIPFamilies sets the IP family policy and families of a generated service as per spec; cluster defaults
+// apply otherwise
+func setIPFamilies(service *corev1.Service, ixia *networkv1beta1.IxiaTG) {
+	if ixia.Spec.IPFamilyPolicy != "" {
+		policy := corev1.IPFamilyPolicy(ixia.Spec.IPFamilyPolicy)
+		service.Spec.IPFamilyPolicy = &policy
+	}
+	for _, family := range ixia.Spec.IPFamilies {
+		service.Spec.IPFamilies = append(service.Spec.IPFamilies, corev1.IPFamily(family))
+	}
+}
+
+// hostPort joins host and port of an endpoint, enclosing IPv6 addresses in brackets
+func hostPort(host string, port int32) string {
+	return net.JoinHostPort(host, strconv.Itoa(int(port)))
+}
+
+// applyService applies a generated service; the primary IP family of a service cannot be changed, so a
+// service of another primary family is deleted and then recreated
+func (r *IxiaTGReconciler) applyService(ctx context.Context, service *corev1.Service, release string, update bool) error {
+	if update && len(service.Spec.IPFamilies) > 0 {
+		key := client.ObjectKeyFromObject(service)
+		existing := &corev1.Service{}
+		err := r.Get(ctx, key, existing)
+		if err != nil && !errapi.IsNotFound(err) {
+			return err
+		} else if err == nil && len(existing.Spec.IPFamilies) > 0 && existing.Spec.IPFamilies[0] != service.Spec.IPFamilies[0] {
+			log.Infof("Recreating service %v in %v for primary IP family %v", service.Name, service.Namespace, service.Spec.IPFamilies[0])
+			if err = r.deleteGenerated(ctx, existing); err != nil {
+				return err
+			}
+			// Load balancer cleanup may delay the deletion, in which case a later reconcile recreates it
+			reader := r.APIReader
+			if reader == nil {
+				reader = r.Client
+			}
+			if err = reader.Get(ctx, key, existing); err == nil {
+				log.Infof("Service %v in %v still being deleted", service.Name, service.Namespace)
+				return nil
+			} else if !errapi.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+	return r.applyGenerated(ctx, service, release, update)
+}
+
+// serviceHost returns the host of a generated service in controller locations: the cluster IP of its
+// primary IP family, so locations do not depend on the family its name resolves in; the service name is
+// used until a cluster IP is allocated
+func (r *IxiaTGReconciler) serviceHost(ctx context.Context, name string, namespace string) string {
+	key := types.NamespacedName{Name: name, Namespace: namespace}
+	service := &corev1.Service{}
+	// Services just created may not be cached yet
+	err := r.getGenerated(ctx, key, service)
+	if err == nil && service.Spec.ClusterIP != "" && service.Spec.ClusterIP != corev1.ClusterIPNone {
+		return service.Spec.ClusterIP
+	} else if err != nil && !errapi.IsNotFound(err) {
+		log.Errorf("Failed to get service %v in %v - %v", name, namespace, err)
+	}
+	return name + "." + namespace + SERVICE_NAME_SUFFIX
+}
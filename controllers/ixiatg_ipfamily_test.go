@@ -0,0 +1,118 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestValidateIPFamilies(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		families []string
+		wantErr  bool
+	}{
+		{"cluster default", "", nil, false},
+		{"single stack ipv6", "SingleStack", []string{"IPv6"}, false},
+		{"dual stack", "PreferDualStack", []string{"IPv6", "IPv4"}, false},
+		{"require dual stack", "RequireDualStack", nil, false},
+		{"unknown policy", "DualStack", nil, true},
+		{"unknown family", "", []string{"IPv5"}, true},
+		{"duplicate family", "PreferDualStack", []string{"IPv4", "IPv4"}, true},
+		{"three families", "PreferDualStack", []string{"IPv4", "IPv6", "IPv4"}, true},
+		{"single stack with two families", "SingleStack", []string{"IPv4", "IPv6"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ixia := testNode("otg")
+			ixia.Spec.IPFamilyPolicy = tt.policy
+			ixia.Spec.IPFamilies = tt.families
+			if err := validateIPFamilies(ixia); (err != nil) != tt.wantErr {
+				t.Errorf("validateIPFamilies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyServiceFamilyChange(t *testing.T) {
+	existing := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "service-otg-port-eth1", Namespace: "ixia-c"},
+		Spec: corev1.ServiceSpec{
+			ClusterIP:  "10.96.0.10",
+			IPFamilies: []corev1.IPFamily{corev1.IPv4Protocol},
+		},
+	}
+	ixia := testNode("otg", "eth1")
+	ixia.Spec.IPFamilies = []string{"IPv6"}
+	r := testReconciler(t, ixia, existing)
+	ctx := context.Background()
+
+	// Without update the service keeps its family
+	if err := r.applyService(ctx, portService("otg-port-eth1", ixia), "test-ipfamily", false); err != nil {
+		t.Fatal(err)
+	}
+	svc := &corev1.Service{}
+	if err := r.Get(ctx, types.NamespacedName{Name: existing.Name, Namespace: existing.Namespace}, svc); err != nil {
+		t.Fatal(err)
+	}
+	if svc.Spec.IPFamilies[0] != corev1.IPv4Protocol {
+		t.Errorf("applyService() without update changed IP families to %v", svc.Spec.IPFamilies)
+	}
+
+	if err := r.applyService(ctx, portService("otg-port-eth1", ixia), "test-ipfamily", true); err != nil {
+		t.Fatal(err)
+	}
+	svc = &corev1.Service{}
+	if err := r.Get(ctx, types.NamespacedName{Name: existing.Name, Namespace: existing.Namespace}, svc); err != nil {
+		t.Fatal(err)
+	}
+	if len(svc.Spec.IPFamilies) != 1 || svc.Spec.IPFamilies[0] != corev1.IPv6Protocol || svc.Spec.ClusterIP == existing.Spec.ClusterIP {
+		t.Errorf("applyService() IP families %v, cluster IP %v, want recreated IPv6 service", svc.Spec.IPFamilies, svc.Spec.ClusterIP)
+	}
+}
+
+func TestServiceHost(t *testing.T) {
+	service := func(name string, clusterIP string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ixia-c"},
+			Spec:       corev1.ServiceSpec{ClusterIP: clusterIP},
+		}
+	}
+	r := testReconciler(t, service("service-ipv4", "10.96.0.10"), service("service-ipv6", "fd00:10:96::a"), service("service-headless", corev1.ClusterIPNone))
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"service-ipv4", "10.96.0.10:5555"},
+		{"service-ipv6", "[fd00:10:96::a]:5555"},
+		{"service-headless", "service-headless.ixia-c" + SERVICE_NAME_SUFFIX + ":5555"},
+		{"service-missing", "service-missing.ixia-c" + SERVICE_NAME_SUFFIX + ":5555"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hostPort(r.serviceHost(context.Background(), tt.name, "ixia-c"), TRAFFIC_ENG_PORT); got != tt.want {
+				t.Errorf("serviceHost() location %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
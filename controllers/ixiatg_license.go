@@ -0,0 +1,332 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	errapi "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	networkv1beta1 "github.com/open-traffic-generator/keng-operator/api/v1beta1"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	CONDITION_LICENSE_CONFIGURED string = "LicenseConfigured"
+
+	LICENSE_REASON_SECRET    string = "SecretRef"
+	LICENSE_REASON_SERVERS   string = "Servers"
+	LICENSE_REASON_BUNDLED   string = "Bundled"
+	LICENSE_REASON_INVALID   string = "Invalid"
+	LICENSE_SECRET_ADDR_KEY  string = "addresses"
+	LICENSE_BUNDLED_ADDRESS  string = "localhost"
+	LICENSE_SERVER_SEPARATOR string = " "
+
+	SHARED_LICENSE_NAME string = "ixiatg-license-server"
+)
+
+// validLicenseAddress verifies a license server address given as host or host:port
+func validLicenseAddress(addr string) error {
+	host := addr
+	if h, port, err := net.SplitHostPort(addr); err == nil {
+		host = h
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			return errors.New(fmt.Sprintf("Invalid port in license server address %s", addr))
+		}
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if errs := validation.IsDNS1123Subdomain(host); len(errs) > 0 {
+		return errors.New(fmt.Sprintf("Invalid license server address %s - %s", addr, strings.Join(errs, ", ")))
+	}
+	return nil
+}
+
+func validLicenseAddresses(addrs []string) error {
+	if len(addrs) == 0 {
+		return errors.New("No license server addresses specified")
+	}
+	for _, addr := range addrs {
+		if err := validLicenseAddress(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateLicense verifies the license configuration in spec
+func validateLicense(ixia *networkv1beta1.IxiaTG) error {
+	lic := ixia.Spec.License
+	if lic == nil {
+		return nil
+	}
+	options := 0
+	if lic.SecretRef != "" {
+		options++
+	}
+	if len(lic.Servers) > 0 {
+		options++
+	}
+	if lic.Bundled {
+		options++
+	}
+	if options != 1 {
+		return errors.New("License must specify exactly one of secret_ref, servers or bundled")
+	}
+	if len(lic.Servers) > 0 {
+		return validLicenseAddresses(lic.Servers)
+	}
+	return nil
+}
+
+// specLicense resolves the license servers specified in spec and the reason of the LicenseConfigured
+// condition; bundled reports if the license server container of the release is deployed
+func (r *IxiaTGReconciler) specLicense(ctx context.Context, ixia *networkv1beta1.IxiaTG, release string) (servers string, bundled bool, reason string, err error) {
+	lic := ixia.Spec.License
+	switch {
+	case lic.Bundled:
+		containers := relDep(release).Controller.Containers
+		_, image := containers[IMAGE_LICENSE_SERVER]
+		_, secretImage := containers[IMAGE_LICENSE_SECRET]
+		if !image && !secretImage {
+			return "", false, "", errors.New(fmt.Sprintf("Failed to find license server entry in configmap for release %s", release))
+		}
+		return LICENSE_BUNDLED_ADDRESS, true, LICENSE_REASON_BUNDLED, nil
+	case len(lic.Servers) > 0:
+		return strings.Join(lic.Servers, LICENSE_SERVER_SEPARATOR), false, LICENSE_REASON_SERVERS, nil
+	}
+
+	secret, err := r.GetSecret(ctx, lic.SecretRef, ixia.Namespace)
+	if err != nil {
+		return "", false, "", err
+	} else if secret == nil {
+		return "", false, "", errors.New(fmt.Sprintf("License secret %s not found in %s", lic.SecretRef, ixia.Namespace))
+	}
+	data, ok := secret.Data[LICENSE_SECRET_ADDR_KEY]
+	if !ok {
+		return "", false, "", errors.New(fmt.Sprintf("License secret %s has no %s", lic.SecretRef, LICENSE_SECRET_ADDR_KEY))
+	}
+	addrs := strings.Fields(strings.ReplaceAll(string(data), ",", LICENSE_SERVER_SEPARATOR))
+	if err = validLicenseAddresses(addrs); err != nil {
+		return "", false, "", errors.New(fmt.Sprintf("License secret %s - %v", lic.SecretRef, err))
+	}
+	return strings.Join(addrs, LICENSE_SERVER_SEPARATOR), false, LICENSE_REASON_SECRET, nil
+}
+
+// reconcileLicense resolves the license configuration in spec and sets the LicenseConfigured condition;
+// returns whether the condition changed
+func (r *IxiaTGReconciler) reconcileLicense(ctx context.Context, ixia *networkv1beta1.IxiaTG) (bool, error) {
+	if ixia.Spec.License == nil {
+		return meta.RemoveStatusCondition(&ixia.Status.Conditions, CONDITION_LICENSE_CONFIGURED), nil
+	}
+	release := getLatestVersion()
+	if ixia.Spec.Release != "" && ixia.Spec.Release != DEFAULT_VERSION {
+		release = ixia.Spec.Release
+	}
+	cond := metav1.Condition{Type: CONDITION_LICENSE_CONFIGURED, ObservedGeneration: ixia.Generation}
+	servers, _, reason, err := r.specLicense(ctx, ixia, release)
+	if err != nil {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = LICENSE_REASON_INVALID
+		cond.Message = err.Error()
+	} else {
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = reason
+		cond.Message = fmt.Sprintf("License servers %s", servers)
+	}
+	return meta.SetStatusCondition(&ixia.Status.Conditions, cond), err
+}
+
+// sharedLicenseAddress returns the address of the shared license server Service in the operator namespace
+func sharedLicenseAddress() string {
+	return SHARED_LICENSE_NAME + "." + CURRENT_NAMESPACE + SERVICE_NAME_SUFFIX
+}
+
+// sharedLicenseContainer builds the license server container of the shared license server from the release
+// component and operator settings only; settings of the node deploying it, e.g. its image pull policy, are
+// not applied as the server is shared by all nodes
+func (r *IxiaTGReconciler) sharedLicenseContainer(release string) (corev1.Container, error) {
+	containers := relDep(release).Controller.Containers
+	comp, ok := containers[IMAGE_LICENSE_SECRET]
+	if !ok {
+		if comp, ok = containers[IMAGE_LICENSE_SERVER]; !ok {
+			return corev1.Container{}, errors.New(fmt.Sprintf("Failed to find license server entry in configmap for release %s", release))
+		}
+	}
+	container := corev1.Container{
+		Name:                     LICENSE_NAME,
+		Image:                    r.imageName(comp.Path, comp.Tag, comp.Digest),
+		ImagePullPolicy:          pullPolicy(&networkv1beta1.IxiaTG{}, comp),
+		TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+	}
+	if comp.Port != 0 {
+		port := corev1.ContainerPort{Name: comp.ContainerName, ContainerPort: comp.Port, Protocol: "TCP"}
+		container.Ports = []corev1.ContainerPort{port}
+	}
+	resRequest := corev1.ResourceList{}
+	if r, ok := comp.MinResource["cpu"]; ok {
+		resRequest["cpu"] = resource.MustParse(r)
+	}
+	if r, ok := comp.MinResource["memory"]; ok {
+		resRequest["memory"] = resource.MustParse(r)
+	}
+	container.Resources.Requests = resRequest
+	setProbes(&container, comp, CTRL_LICENSE_PORT)
+	updateControllerContainer(&container, comp, false, false)
+	return container, nil
+}
+
+// sharedLicenseReleases returns the releases of the live nodes using the shared license server, i.e. those to
+// be deployed without license servers in spec; the node being deleted, if any, is not counted
+func (r *IxiaTGReconciler) sharedLicenseReleases(ctx context.Context, deleted *networkv1beta1.IxiaTG) (map[string]bool, error) {
+	nodes := &networkv1beta1.IxiaTGList{}
+	if err := r.List(ctx, nodes); err != nil {
+		log.Errorf("Failed to get list of IxiaTG nodes - %v", err)
+		return nil, err
+	}
+	releases := map[string]bool{}
+	for _, node := range nodes.Items {
+		if node.Spec.License != nil || node.Spec.DesiredState != STATE_DEPLOYED || !node.DeletionTimestamp.IsZero() {
+			continue
+		} else if deleted != nil && node.Namespace == deleted.Namespace && node.Name == deleted.Name {
+			continue
+		}
+		release := getLatestVersion()
+		if node.Spec.Release != "" && node.Spec.Release != DEFAULT_VERSION {
+			release = node.Spec.Release
+		}
+		releases[release] = true
+	}
+	return releases, nil
+}
+
+// applySharedLicenseServer applies the shared license server Deployment and Service in the operator namespace,
+// running the license server container of the release; updates are gated on the spec hash. A server deployed
+// for another release still in use is only moved to the latest release, so nodes of different releases do
+// not roll it back and forth
+func (r *IxiaTGReconciler) applySharedLicenseServer(ctx context.Context, release string) error {
+	deploy := &appsv1.Deployment{}
+	err := r.Get(ctx, types.NamespacedName{Name: SHARED_LICENSE_NAME, Namespace: CURRENT_NAMESPACE}, deploy)
+	if err != nil && !errapi.IsNotFound(err) {
+		return err
+	} else if deployed := deploy.Annotations[RELEASE_ANNOTATION]; err == nil && deployed != release && release != getLatestVersion() {
+		releases, err := r.sharedLicenseReleases(ctx, nil)
+		if err != nil {
+			return err
+		} else if releases[deployed] {
+			log.Infof("Shared license server retained for release %s", deployed)
+			return nil
+		}
+	}
+	container, err := r.sharedLicenseContainer(release)
+	if err != nil {
+		return err
+	}
+
+	labels := map[string]string{"app": SHARED_LICENSE_NAME}
+	pullSecrets := []corev1.LocalObjectReference{}
+	for _, name := range r.ImagePullSecrets {
+		pullSecrets = append(pullSecrets, corev1.LocalObjectReference{Name: name})
+	}
+	deploy = &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      SHARED_LICENSE_NAME,
+			Namespace: CURRENT_NAMESPACE,
+			Labels:    labels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: pointer.Int32(1),
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Strategy: appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers:                    []corev1.Container{container},
+					TerminationGracePeriodSeconds: pointer.Int64(TERMINATION_TIMEOUT_SEC),
+					ImagePullSecrets:              pullSecrets,
+				},
+			},
+		},
+	}
+	log.Infof("Applying shared license server deployment for release %s", release)
+	if err = r.applyGenerated(ctx, deploy, release, true); err != nil {
+		log.Errorf("Failed to apply deployment %v in %v, err %v", deploy.Name, deploy.Namespace, err)
+		return err
+	}
+
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      SHARED_LICENSE_NAME,
+			Namespace: CURRENT_NAMESPACE,
+			Labels:    labels,
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{
+				Name:       LICENSE_NAME,
+				Protocol:   corev1.ProtocolTCP,
+				Port:       CTRL_LICENSE_PORT,
+				TargetPort: intstr.FromInt(int(CTRL_LICENSE_PORT)),
+			}},
+			Selector: labels,
+		},
+	}
+	if err = r.applyGenerated(ctx, service, release, true); err != nil {
+		log.Errorf("Failed to apply service %v in %v, err %v", service.Name, service.Namespace, err)
+		return err
+	}
+	return nil
+}
+
+// releaseSharedLicenseServer deletes the shared license server Deployment and Service once no live node uses
+// them, or when the operator runs without the shared license server; the node being deleted, if any, is not
+// counted
+func (r *IxiaTGReconciler) releaseSharedLicenseServer(ctx context.Context, deleted *networkv1beta1.IxiaTG) error {
+	if r.SharedLicenseServer {
+		releases, err := r.sharedLicenseReleases(ctx, deleted)
+		if err != nil || len(releases) > 0 {
+			return err
+		}
+	}
+	objMeta := metav1.ObjectMeta{Name: SHARED_LICENSE_NAME, Namespace: CURRENT_NAMESPACE}
+	deploy := &appsv1.Deployment{ObjectMeta: objMeta}
+	if err := r.getGenerated(ctx, client.ObjectKeyFromObject(deploy), deploy); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	log.Infof("Deleting unused shared license server")
+	// The Service goes first, so a failed cleanup is retried while the Deployment remains
+	if err := r.deleteGenerated(ctx, &corev1.Service{ObjectMeta: objMeta}); err != nil {
+		return err
+	}
+	return r.deleteGenerated(ctx, deploy)
+}
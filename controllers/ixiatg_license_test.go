@@ -0,0 +1,248 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	errapi "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	networkv1beta1 "github.com/open-traffic-generator/keng-operator/api/v1beta1"
+)
+
+func TestValidateLicense(t *testing.T) {
+	tests := []struct {
+		name    string
+		license *networkv1beta1.IxiaTGLicense
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"secret", &networkv1beta1.IxiaTGLicense{SecretRef: "lic"}, false},
+		{"bundled", &networkv1beta1.IxiaTGLicense{Bundled: true}, false},
+		{"servers", &networkv1beta1.IxiaTGLicense{Servers: []string{"10.0.0.1", "lic.lab:7443", "[2001:db8::1]:7443"}}, false},
+		{"empty", &networkv1beta1.IxiaTGLicense{}, true},
+		{"multiple options", &networkv1beta1.IxiaTGLicense{SecretRef: "lic", Bundled: true}, true},
+		{"invalid host", &networkv1beta1.IxiaTGLicense{Servers: []string{"Lic_Server"}}, true},
+		{"invalid port", &networkv1beta1.IxiaTGLicense{Servers: []string{"10.0.0.1:70000"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ixia := testNode("otg")
+			ixia.Spec.License = tt.license
+			if err := validateLicense(ixia); (err != nil) != tt.wantErr {
+				t.Errorf("validateLicense() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReconcileLicense(t *testing.T) {
+	secret := func(data map[string][]byte) *corev1.Secret {
+		return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "lic", Namespace: "ixia-c"}, Data: data}
+	}
+	tests := []struct {
+		name       string
+		license    *networkv1beta1.IxiaTGLicense
+		secret     *corev1.Secret
+		wantErr    bool
+		wantReason string
+		wantMsg    string
+	}{
+		{"servers", &networkv1beta1.IxiaTGLicense{Servers: []string{"10.0.0.1", "10.0.0.2"}}, nil, false, LICENSE_REASON_SERVERS, "License servers 10.0.0.1 10.0.0.2"},
+		{"secret", &networkv1beta1.IxiaTGLicense{SecretRef: "lic"}, secret(map[string][]byte{LICENSE_SECRET_ADDR_KEY: []byte("10.0.0.1, lic.lab")}), false, LICENSE_REASON_SECRET, "License servers 10.0.0.1 lic.lab"},
+		{"missing secret", &networkv1beta1.IxiaTGLicense{SecretRef: "lic"}, nil, true, LICENSE_REASON_INVALID, ""},
+		{"secret without addresses", &networkv1beta1.IxiaTGLicense{SecretRef: "lic"}, secret(map[string][]byte{"servers": []byte("10.0.0.1")}), true, LICENSE_REASON_INVALID, ""},
+		{"bundled without image", &networkv1beta1.IxiaTGLicense{Bundled: true}, nil, true, LICENSE_REASON_INVALID, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ixia := testNode("otg")
+			ixia.Spec.Release = "test-license"
+			ixia.Spec.License = tt.license
+			r := testReconciler(t)
+			if tt.secret != nil {
+				r = testReconciler(t, tt.secret)
+			}
+			testRelease(t, r, ixia.Spec.Release)
+
+			changed, err := r.reconcileLicense(context.Background(), ixia)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reconcileLicense() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			cond := meta.FindStatusCondition(ixia.Status.Conditions, CONDITION_LICENSE_CONFIGURED)
+			if !changed || cond == nil || cond.Reason != tt.wantReason || (tt.wantMsg != "" && cond.Message != tt.wantMsg) {
+				t.Errorf("reconcileLicense() changed %v, condition %+v, want reason %v message %q", changed, cond, tt.wantReason, tt.wantMsg)
+			}
+		})
+	}
+}
+
+// testLicenseRelease loads a release including a license server of the given tag into the release cache
+func testLicenseRelease(t *testing.T, r *IxiaTGReconciler, release string, licenseTag string) {
+	t.Helper()
+	data := []byte(`{"release": "` + release + `", "images": [
+		{"name": "controller", "path": "ghcr.io/open-traffic-generator/keng-controller", "tag": "1.13.0-1"},
+		{"name": "gnmi-server", "path": "ghcr.io/open-traffic-generator/otg-gnmi-server", "tag": "1.14.14"},
+		{"name": "license-server", "path": "ghcr.io/open-traffic-generator/keng-license-server", "tag": "` + licenseTag + `"},
+		{"name": "traffic-engine", "path": "ghcr.io/open-traffic-generator/ixia-c-traffic-engine", "tag": "1.8.0.25"},
+		{"name": "protocol-engine", "path": "ghcr.io/open-traffic-generator/ixia-c-protocol-engine", "tag": "1.00.0.399"}
+	]}`)
+	if err := r.loadRelInfo(context.Background(), release, &data, false, DS_RESTAPI, ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSharedLicenseServer(t *testing.T) {
+	ixia := testNode("otg", "eth1")
+	ixia.Spec.Release = "test-shared-license"
+	ixia.Spec.ImagePullPolicy = map[string]string{ALL_COMPONENTS: string(corev1.PullNever)}
+	r := testReconciler(t, ixia)
+	r.SharedLicenseServer = true
+	testLicenseRelease(t, r, ixia.Spec.Release, "1.0.0")
+
+	containers, err := r.containersForController(context.Background(), ixia, ixia.Spec.Release, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range containers {
+		if c.Name == LICENSE_NAME {
+			t.Errorf("containersForController() added license server container to node with shared license server")
+		}
+	}
+	deploy := &appsv1.Deployment{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: SHARED_LICENSE_NAME, Namespace: CURRENT_NAMESPACE}, deploy); err != nil {
+		t.Fatal(err)
+	}
+	c := deploy.Spec.Template.Spec.Containers[0]
+	if c.Image != "ghcr.io/open-traffic-generator/keng-license-server:1.0.0" || c.ImagePullPolicy == corev1.PullNever {
+		t.Errorf("shared license server image %v, pull policy %v, want release image and default pull policy", c.Image, c.ImagePullPolicy)
+	}
+}
+
+func TestApplySharedLicenseServer(t *testing.T) {
+	tests := []struct {
+		name string
+		// deployed is the release the shared license server runs, inUse whether a live node still uses it
+		deployed  string
+		inUse     bool
+		wantImage string
+	}{
+		{"create", "", false, "ghcr.io/open-traffic-generator/keng-license-server:2.0.0"},
+		{"same release", "test-shared-new", true, "ghcr.io/open-traffic-generator/keng-license-server:2.0.0"},
+		{"other release in use", "test-shared-old", true, "ghcr.io/open-traffic-generator/keng-license-server:1.0.0"},
+		{"other release unused", "test-shared-old", false, "ghcr.io/open-traffic-generator/keng-license-server:2.0.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := testNode("otg", "eth1")
+			node.Spec.Release = "test-shared-new"
+			node.Spec.DesiredState = STATE_DEPLOYED
+			objs := []client.Object{node}
+			if tt.inUse {
+				other := testNode("otg", "eth1")
+				other.Namespace = "other"
+				other.Spec.Release = tt.deployed
+				other.Spec.DesiredState = STATE_DEPLOYED
+				objs = append(objs, other)
+			}
+			r := testReconciler(t, objs...)
+			r.SharedLicenseServer = true
+			testLicenseRelease(t, r, "test-shared-old", "1.0.0")
+			testLicenseRelease(t, r, "test-shared-new", "2.0.0")
+
+			ctx := context.Background()
+			if tt.deployed != "" {
+				if err := r.applySharedLicenseServer(ctx, tt.deployed); err != nil {
+					t.Fatalf("applySharedLicenseServer() error = %v", err)
+				}
+			}
+			if err := r.applySharedLicenseServer(ctx, node.Spec.Release); err != nil {
+				t.Fatalf("applySharedLicenseServer() error = %v", err)
+			}
+			deploy := &appsv1.Deployment{}
+			if err := r.Get(ctx, types.NamespacedName{Name: SHARED_LICENSE_NAME, Namespace: CURRENT_NAMESPACE}, deploy); err != nil {
+				t.Fatal(err)
+			}
+			if image := deploy.Spec.Template.Spec.Containers[0].Image; image != tt.wantImage {
+				t.Errorf("shared license server image = %v, want %v", image, tt.wantImage)
+			}
+
+			// Matching the spec hash, the server is left unchanged
+			version := deploy.ResourceVersion
+			if err := r.applySharedLicenseServer(ctx, node.Spec.Release); err != nil {
+				t.Fatalf("applySharedLicenseServer() error = %v", err)
+			}
+			if err := r.Get(ctx, client.ObjectKeyFromObject(deploy), deploy); err != nil || deploy.ResourceVersion != version {
+				t.Errorf("shared license server updated, version %v -> %v (%v)", version, deploy.ResourceVersion, err)
+			}
+		})
+	}
+}
+
+func TestReleaseSharedLicenseServer(t *testing.T) {
+	deployed := func(name string, namespace string) *networkv1beta1.IxiaTG {
+		node := testNode(name, "eth1")
+		node.Namespace = namespace
+		node.Spec.Release = "test-shared-release"
+		node.Spec.DesiredState = STATE_DEPLOYED
+		return node
+	}
+	licensed := deployed("otg", "licensed")
+	licensed.Spec.License = &networkv1beta1.IxiaTGLicense{Bundled: true}
+	tests := []struct {
+		name        string
+		flag        bool
+		others      []client.Object
+		wantDeleted bool
+	}{
+		{"last node", true, nil, true},
+		{"other node", true, []client.Object{deployed("otg", "other")}, false},
+		{"other node with license", true, []client.Object{licensed}, true},
+		{"flag removed", false, []client.Object{deployed("otg", "other")}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := deployed("otg", "ixia-c")
+			r := testReconciler(t, append([]client.Object{node}, tt.others...)...)
+			r.SharedLicenseServer = true
+			testLicenseRelease(t, r, node.Spec.Release, "1.0.0")
+
+			ctx := context.Background()
+			if err := r.applySharedLicenseServer(ctx, node.Spec.Release); err != nil {
+				t.Fatalf("applySharedLicenseServer() error = %v", err)
+			}
+			r.SharedLicenseServer = tt.flag
+			if err := r.releaseSharedLicenseServer(ctx, node); err != nil {
+				t.Fatalf("releaseSharedLicenseServer() error = %v", err)
+			}
+			key := types.NamespacedName{Name: SHARED_LICENSE_NAME, Namespace: CURRENT_NAMESPACE}
+			for _, obj := range []client.Object{&appsv1.Deployment{}, &corev1.Service{}} {
+				err := r.Get(ctx, key, obj)
+				if deleted := errapi.IsNotFound(err); deleted != tt.wantDeleted {
+					t.Errorf("shared license server %T deleted %v, want %v (%v)", obj, deleted, tt.wantDeleted, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	networkv1beta1 "github.com/open-traffic-generator/keng-operator/api/v1beta1"
+)
+
+const (
+	MULTUS_NETWORKS_ANNOTATION string = "k8s.v1.cni.cncf.io/networks"
+)
+
+// multusNetwork is an entry of the Multus networks annotation, attaching an interface of the given name
+// through a NetworkAttachmentDefinition
+type multusNetwork struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Interface string `json:"interface,omitempty"`
+}
+
+// validateNetworks verifies the NetworkAttachmentDefinition references of interfaces, as name or namespace/name
+func validateNetworks(ixia *networkv1beta1.IxiaTG) error {
+	for _, intf := range ixia.Spec.Interfaces {
+		if intf.Network == "" {
+			continue
+		}
+		for _, part := range strings.SplitN(intf.Network, "/", 2) {
+			if errs := validation.IsDNS1123Subdomain(part); len(errs) > 0 {
+				return errors.New(fmt.Sprintf("Invalid network %s of interface %s - %s", intf.Network, intf.Name, strings.Join(errs, ", ")))
+			}
+		}
+	}
+	return nil
+}
+
+// multusNetworks returns the Multus attachments of the interfaces of a port pod; interfaces without a network
+// are wired by meshnet
+func multusNetworks(ixia *networkv1beta1.IxiaTG, intfList []string) []multusNetwork {
+	networks := []multusNetwork{}
+	for _, name := range intfList {
+		for _, intf := range ixia.Spec.Interfaces {
+			if intf.Name != name || intf.Network == "" {
+				continue
+			}
+			network := multusNetwork{Name: intf.Network, Interface: intf.Name}
+			if parts := strings.SplitN(intf.Network, "/", 2); len(parts) == 2 {
+				network.Namespace = parts[0]
+				network.Name = parts[1]
+			}
+			networks = append(networks, network)
+		}
+	}
+	return networks
+}
+
+// setNetworksAnnotation requests the Multus attachments of the port pod through its networks annotation
+func setNetworksAnnotation(pod *corev1.Pod, networks []multusNetwork) error {
+	if len(networks) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(networks)
+	if err != nil {
+		return err
+	}
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[MULTUS_NETWORKS_ANNOTATION] = string(data)
+	return nil
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestValidateNetworks(t *testing.T) {
+	tests := []struct {
+		name    string
+		network string
+		wantErr bool
+	}{
+		{"meshnet", "", false},
+		{"name", "macvlan-conf", false},
+		{"namespaced name", "lab-networks/macvlan-conf", false},
+		{"invalid name", "Macvlan_Conf", true},
+		{"invalid namespace", "Lab/macvlan-conf", true},
+		{"nested name", "lab-networks/macvlan/conf", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ixia := testNode("otg", "eth1")
+			ixia.Spec.Interfaces[0].Network = tt.network
+			if err := validateNetworks(ixia); (err != nil) != tt.wantErr {
+				t.Errorf("validateNetworks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMultusNetworks(t *testing.T) {
+	ixia := testNode("otg", "eth1", "eth2", "eth3", "eth4")
+	ixia.Spec.Interfaces[0].Network = "macvlan-conf"
+	ixia.Spec.Interfaces[2].Network = "lab-networks/sriov-conf"
+	ixia.Spec.Interfaces[3].Network = "macvlan-conf"
+
+	tests := []struct {
+		name     string
+		intfList []string
+		want     []multusNetwork
+	}{
+		{"meshnet only", []string{"eth2"}, []multusNetwork{}},
+		{"single pod", []string{"eth1", "eth2", "eth3", "eth4"}, []multusNetwork{
+			{Name: "macvlan-conf", Interface: "eth1"},
+			{Name: "sriov-conf", Namespace: "lab-networks", Interface: "eth3"},
+			{Name: "macvlan-conf", Interface: "eth4"},
+		}},
+		{"pod order", []string{"eth4", "eth3"}, []multusNetwork{
+			{Name: "macvlan-conf", Interface: "eth4"},
+			{Name: "sriov-conf", Namespace: "lab-networks", Interface: "eth3"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := multusNetworks(ixia, tt.intfList); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("multusNetworks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetNetworksAnnotation(t *testing.T) {
+	pod := &corev1.Pod{}
+	if err := setNetworksAnnotation(pod, []multusNetwork{}); err != nil || pod.Annotations != nil {
+		t.Errorf("setNetworksAnnotation() without networks set annotations %v, err %v", pod.Annotations, err)
+	}
+	networks := []multusNetwork{{Name: "macvlan-conf", Interface: "eth1"}, {Name: "sriov-conf", Namespace: "lab-networks", Interface: "eth2"}}
+	if err := setNetworksAnnotation(pod, networks); err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"name":"macvlan-conf","interface":"eth1"},{"name":"sriov-conf","namespace":"lab-networks","interface":"eth2"}]`
+	if got := pod.Annotations[MULTUS_NETWORKS_ANNOTATION]; got != want {
+		t.Errorf("setNetworksAnnotation() annotation %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	networkv1beta1 "github.com/open-traffic-generator/keng-operator/api/v1beta1"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	NETPOL_NAME_PREFIX string = "netpol-"
+	NETPOL_PORTS_INFIX string = "-ports"
+	NAMESPACE_LABEL    string = "kubernetes.io/metadata.name"
+)
+
+func validateNetworkPolicy(ixia *networkv1beta1.IxiaTG) error {
+	policy := ixia.Spec.NetworkPolicy
+	if policy == nil {
+		return nil
+	}
+	for _, cidr := range policy.ClientCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return errors.New(fmt.Sprintf("Invalid client CIDR %s - %v", cidr, err))
+		}
+	}
+	for _, ns := range policy.ClientNamespaces {
+		if errs := validation.IsDNS1123Label(ns); len(errs) > 0 {
+			return errors.New(fmt.Sprintf("Invalid client namespace %s - %s", ns, strings.Join(errs, ", ")))
+		}
+	}
+	// Ingress controllers and gateways connect from their own pods, which would be denied unless their
+	// namespace is listed; which namespace that is cannot be told from spec
+	if len(policy.ClientCIDRs) > 0 && len(policy.ClientNamespaces) == 0 {
+		for _, name := range sortedEndpoints(ixia) {
+			if exposed := exposeType(ixia.Spec.ApiEndPoint[name]); exposed != EXPOSE_LOAD_BALANCER {
+				return errors.New(fmt.Sprintf("Network policy would deny %s traffic to api endpoint %s; list the namespace of the ingress controller or gateway under client_namespaces", exposed, name))
+			}
+		}
+	}
+	return nil
+}
+
+func networkPolicyNames(ixia *networkv1beta1.IxiaTG) []string {
+	return []string{
+		NETPOL_NAME_PREFIX + ixia.Name + CTRL_POD_NAME_SUFFIX,
+		NETPOL_NAME_PREFIX + ixia.Name + NETPOL_PORTS_INFIX,
+	}
+}
+
+// getNetworkPolicies returns the policies isolating the node: port pods only accept traffic and protocol
+// engine connections from the controller, and the controller only accepts api endpoint connections from
+// the configured clients
+func (r *IxiaTGReconciler) getNetworkPolicies(ixia *networkv1beta1.IxiaTG) ([]*networkingv1.NetworkPolicy, error) {
+	names := networkPolicyNames(ixia)
+	ctrlPodName := ixia.Name + CTRL_POD_NAME_SUFFIX
+	tcp := corev1.ProtocolTCP
+
+	clients := []networkingv1.NetworkPolicyPeer{}
+	for _, cidr := range ixia.Spec.NetworkPolicy.ClientCIDRs {
+		clients = append(clients, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+	}
+	if len(ixia.Spec.NetworkPolicy.ClientNamespaces) > 0 {
+		selector := &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{{
+				Key:      NAMESPACE_LABEL,
+				Operator: metav1.LabelSelectorOpIn,
+				Values:   ixia.Spec.NetworkPolicy.ClientNamespaces,
+			}},
+		}
+		clients = append(clients, networkingv1.NetworkPolicyPeer{NamespaceSelector: selector})
+	}
+	apiPorts := []networkingv1.NetworkPolicyPort{}
+	for _, name := range sortedEndpoints(ixia) {
+		port := intstr.FromInt(int(ixia.Spec.ApiEndPoint[name].In))
+		apiPorts = append(apiPorts, networkingv1.NetworkPolicyPort{Protocol: &tcp, Port: &port})
+	}
+	ctrlPolicy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      names[0],
+			Namespace: ixia.Namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": ctrlPodName}},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress:     []networkingv1.NetworkPolicyIngressRule{{Ports: apiPorts, From: clients}},
+		},
+	}
+
+	tePort := intstr.FromInt(int(TRAFFIC_ENG_PORT))
+	pePort := intstr.FromInt(int(PROTOCOL_ENG_PORT))
+	portsPolicy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      names[1],
+			Namespace: ixia.Namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{NODE_LABEL: ixia.Name, "topo": ixia.Namespace}},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				Ports: []networkingv1.NetworkPolicyPort{
+					{Protocol: &tcp, Port: &tePort},
+					{Protocol: &tcp, Port: &pePort},
+				},
+				From: []networkingv1.NetworkPolicyPeer{{
+					PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": ctrlPodName}},
+				}},
+			}},
+		},
+	}
+
+	policies := []*networkingv1.NetworkPolicy{ctrlPolicy, portsPolicy}
+	for _, policy := range policies {
+		if err := controllerutil.SetControllerReference(ixia, policy, r.Scheme); err != nil {
+			return nil, err
+		}
+	}
+	return policies, nil
+}
+
+// sortedEndpoints returns the api endpoint names in a stable order
+func sortedEndpoints(ixia *networkv1beta1.IxiaTG) []string {
+	names := []string{}
+	for name := range ixia.Spec.ApiEndPoint {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// reconcileNetworkPolicies applies the network policies of the node if enabled in spec, deleting them otherwise
+func (r *IxiaTGReconciler) reconcileNetworkPolicies(ctx context.Context, ixia *networkv1beta1.IxiaTG, release string) error {
+	if ixia.Spec.NetworkPolicy == nil {
+		return r.deleteNetworkPolicies(ctx, ixia)
+	}
+	policies, err := r.getNetworkPolicies(ixia)
+	if err != nil {
+		return err
+	}
+	for _, policy := range policies {
+		if err = r.applyGenerated(ctx, policy, release, autoUpdate(ixia)); err != nil {
+			log.Errorf("Failed to apply network policy %v in %v, err %v", policy.Name, ixia.Namespace, err)
+			return err
+		}
+		log.Infof("Applied network policy %v", policy.Name)
+	}
+	return nil
+}
+
+// deleteNetworkPolicies deletes the network policies of the node, if any
+func (r *IxiaTGReconciler) deleteNetworkPolicies(ctx context.Context, ixia *networkv1beta1.IxiaTG) error {
+	for _, name := range networkPolicyNames(ixia) {
+		policy := &networkingv1.NetworkPolicy{}
+		if r.Get(ctx, types.NamespacedName{Name: name, Namespace: ixia.Namespace}, policy) == nil {
+			if err := r.Delete(ctx, policy); err != nil {
+				log.Errorf("Failed to delete network policy %v - %v", policy.Name, err)
+				return err
+			}
+			log.Infof("Deleted network policy %v", policy.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	networkv1beta1 "github.com/open-traffic-generator/keng-operator/api/v1beta1"
+)
+
+func TestValidateNetworkPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  *networkv1beta1.IxiaTGNetworkPolicy
+		expose  string
+		wantErr bool
+	}{
+		{"none", nil, EXPOSE_INGRESS, false},
+		{"open", &networkv1beta1.IxiaTGNetworkPolicy{}, EXPOSE_INGRESS, false},
+		{"cidrs", &networkv1beta1.IxiaTGNetworkPolicy{ClientCIDRs: []string{"10.20.0.0/16", "2001:db8::/64"}}, EXPOSE_LOAD_BALANCER, false},
+		{"namespaces", &networkv1beta1.IxiaTGNetworkPolicy{ClientNamespaces: []string{"ixia-c-clients"}}, EXPOSE_LOAD_BALANCER, false},
+		{"invalid cidr", &networkv1beta1.IxiaTGNetworkPolicy{ClientCIDRs: []string{"10.20.0.0"}}, EXPOSE_LOAD_BALANCER, true},
+		{"invalid namespace", &networkv1beta1.IxiaTGNetworkPolicy{ClientNamespaces: []string{"Ixia_C"}}, EXPOSE_LOAD_BALANCER, true},
+		{"ingress with cidrs only", &networkv1beta1.IxiaTGNetworkPolicy{ClientCIDRs: []string{"10.20.0.0/16"}}, EXPOSE_INGRESS, true},
+		{"gateway with cidrs only", &networkv1beta1.IxiaTGNetworkPolicy{ClientCIDRs: []string{"10.20.0.0/16"}}, EXPOSE_GATEWAY, true},
+		{"ingress with namespaces", &networkv1beta1.IxiaTGNetworkPolicy{ClientCIDRs: []string{"10.20.0.0/16"}, ClientNamespaces: []string{"ingress-nginx"}}, EXPOSE_INGRESS, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ixia := exposedNode(nil, map[string]string{GRPC_ENDPOINT_NAME: tt.expose})
+			ixia.Spec.NetworkPolicy = tt.policy
+			if err := validateNetworkPolicy(ixia); (err != nil) != tt.wantErr {
+				t.Errorf("validateNetworkPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
+)
+
+const (
+	PROBE_LIVENESS  string = "liveness"
+	PROBE_READINESS string = "readiness"
+	PROBE_STARTUP   string = "startup"
+
+	PROBE_TYPE_TCP   string = "tcp"
+	PROBE_TYPE_HTTP  string = "http"
+	PROBE_TYPE_HTTPS string = "https"
+	PROBE_TYPE_GRPC  string = "grpc"
+	PROBE_TYPE_EXEC  string = "exec"
+
+	READINESS_PERIOD  int32 = 5
+	READINESS_FAILURE int32 = 3
+)
+
+// probeRel defines a container probe as specified in the release configmap
+type probeRel struct {
+	Enable       *bool    `json:"enable,omitempty"`
+	Type         string   `json:"type,omitempty"`
+	Port         int32    `json:"port,omitempty"`
+	Path         string   `json:"path,omitempty"`
+	Service      string   `json:"service,omitempty"`
+	Command      []string `json:"command,omitempty"`
+	InitialDelay int32    `json:"initial-delay,omitempty"`
+	Period       int32    `json:"period,omitempty"`
+	Timeout      int32    `json:"timeout,omitempty"`
+	Success      int32    `json:"success,omitempty"`
+	Failure      int32    `json:"failure,omitempty"`
+}
+
+// validateProbes verifies the probes specified for a component in the release configmap
+func validateProbes(comp componentRel) error {
+	for kind, p := range comp.Probes {
+		switch kind {
+		case PROBE_LIVENESS, PROBE_READINESS, PROBE_STARTUP:
+		default:
+			return errors.New(fmt.Sprintf("Unknown probe %s for component %s", kind, comp.Name))
+		}
+		if kind != PROBE_READINESS && p.Success > 1 {
+			return errors.New(fmt.Sprintf("Success threshold must be 1 for %s probe of component %s", kind, comp.Name))
+		}
+		switch p.Type {
+		case "", PROBE_TYPE_TCP, PROBE_TYPE_HTTP, PROBE_TYPE_HTTPS, PROBE_TYPE_GRPC:
+		case PROBE_TYPE_EXEC:
+			if len(p.Command) == 0 {
+				return errors.New(fmt.Sprintf("Command is required for %s %s probe of component %s", p.Type, kind, comp.Name))
+			}
+		default:
+			return errors.New(fmt.Sprintf("Unknown %s probe type %s for component %s", kind, p.Type, comp.Name))
+		}
+	}
+	return nil
+}
+
+// defaultProbe returns the probe parameters used when not specified in the release configmap; liveness
+// parameters retain the legacy liveness-* entries
+func defaultProbe(kind string, comp componentRel) probeRel {
+	switch kind {
+	case PROBE_LIVENESS:
+		return probeRel{Enable: comp.LiveNessEnable, InitialDelay: comp.LiveNessDelay, Period: comp.LiveNessPeriod, Failure: comp.LiveNessFailure}
+	case PROBE_STARTUP:
+		return probeRel{Enable: comp.StartUpEnable, Period: STARTUP_PERIOD, Failure: STARTUP_FAILURE}
+	}
+	return probeRel{Period: READINESS_PERIOD, Failure: READINESS_FAILURE}
+}
+
+// buildProbe builds a container probe, defaulting to a TCP check on the component port
+func buildProbe(kind string, comp componentRel, port int32) *corev1.Probe {
+	p := defaultProbe(kind, comp)
+	if cfg, ok := comp.Probes[kind]; ok {
+		if cfg.Enable != nil {
+			p.Enable = cfg.Enable
+		}
+		p.Type = cfg.Type
+		p.Port = cfg.Port
+		p.Path = cfg.Path
+		p.Service = cfg.Service
+		p.Command = cfg.Command
+		if cfg.InitialDelay != 0 {
+			p.InitialDelay = cfg.InitialDelay
+		}
+		if cfg.Period != 0 {
+			p.Period = cfg.Period
+		}
+		if cfg.Timeout != 0 {
+			p.Timeout = cfg.Timeout
+		}
+		if cfg.Success != 0 {
+			p.Success = cfg.Success
+		}
+		if cfg.Failure != 0 {
+			p.Failure = cfg.Failure
+		}
+	}
+	if p.Enable != nil && !*p.Enable {
+		return nil
+	}
+	if p.Port == 0 {
+		p.Port = port
+	}
+	if p.Port == 0 && p.Type != PROBE_TYPE_EXEC {
+		return nil
+	}
+
+	probePort := intstr.IntOrString{IntVal: p.Port}
+	hdlr := corev1.ProbeHandler{}
+	switch p.Type {
+	case PROBE_TYPE_HTTP, PROBE_TYPE_HTTPS:
+		scheme := corev1.URISchemeHTTP
+		if p.Type == PROBE_TYPE_HTTPS {
+			scheme = corev1.URISchemeHTTPS
+		}
+		hdlr.HTTPGet = &corev1.HTTPGetAction{Path: p.Path, Port: probePort, Scheme: scheme}
+	case PROBE_TYPE_GRPC:
+		hdlr.GRPC = &corev1.GRPCAction{Port: p.Port}
+		if p.Service != "" {
+			hdlr.GRPC.Service = pointer.String(p.Service)
+		}
+	case PROBE_TYPE_EXEC:
+		hdlr.Exec = &corev1.ExecAction{Command: p.Command}
+	default:
+		hdlr.TCPSocket = &corev1.TCPSocketAction{Port: probePort}
+	}
+
+	probe := &corev1.Probe{
+		ProbeHandler:        hdlr,
+		InitialDelaySeconds: p.InitialDelay,
+		PeriodSeconds:       p.Period,
+		TimeoutSeconds:      p.Timeout,
+		SuccessThreshold:    p.Success,
+		FailureThreshold:    p.Failure,
+	}
+	if kind == PROBE_LIVENESS {
+		probe.TerminationGracePeriodSeconds = pointer.Int64(1)
+	}
+	return probe
+}
+
+// setProbes sets readiness, liveness and startup probes of a container
+func setProbes(container *corev1.Container, comp componentRel, port int32) {
+	container.LivenessProbe = buildProbe(PROBE_LIVENESS, comp, port)
+	container.ReadinessProbe = buildProbe(PROBE_READINESS, comp, port)
+	container.StartupProbe = buildProbe(PROBE_STARTUP, comp, port)
+}
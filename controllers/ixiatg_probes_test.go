@@ -0,0 +1,118 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
+)
+
+func TestBuildProbe(t *testing.T) {
+	tcp := func(port int32) corev1.ProbeHandler {
+		return corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.IntOrString{IntVal: port}}}
+	}
+	tests := []struct {
+		name string
+		kind string
+		comp componentRel
+		port int32
+		want *corev1.Probe
+	}{
+		{
+			"default readiness", PROBE_READINESS, componentRel{}, 8443,
+			&corev1.Probe{ProbeHandler: tcp(8443), PeriodSeconds: READINESS_PERIOD, FailureThreshold: READINESS_FAILURE},
+		},
+		{
+			"legacy liveness", PROBE_LIVENESS, componentRel{LiveNessDelay: 10, LiveNessPeriod: 20, LiveNessFailure: 4}, 8443,
+			&corev1.Probe{ProbeHandler: tcp(8443), InitialDelaySeconds: 10, PeriodSeconds: 20, FailureThreshold: 4, TerminationGracePeriodSeconds: pointer.Int64(1)},
+		},
+		{
+			"legacy liveness disabled", PROBE_LIVENESS, componentRel{LiveNessEnable: pointer.Bool(false)}, 8443,
+			nil,
+		},
+		{
+			"enabled over legacy disabled", PROBE_LIVENESS,
+			componentRel{LiveNessEnable: pointer.Bool(false), Probes: map[string]probeRel{PROBE_LIVENESS: {Enable: pointer.Bool(true), Period: 7}}}, 8443,
+			&corev1.Probe{ProbeHandler: tcp(8443), PeriodSeconds: 7, TerminationGracePeriodSeconds: pointer.Int64(1)},
+		},
+		{
+			"no port", PROBE_READINESS, componentRel{}, 0,
+			nil,
+		},
+		{
+			"https with port", PROBE_READINESS,
+			componentRel{Probes: map[string]probeRel{PROBE_READINESS: {Type: PROBE_TYPE_HTTPS, Port: 8444, Path: "/health", Success: 2}}}, 8443,
+			&corev1.Probe{
+				ProbeHandler:     corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/health", Port: intstr.IntOrString{IntVal: 8444}, Scheme: corev1.URISchemeHTTPS}},
+				PeriodSeconds:    READINESS_PERIOD,
+				SuccessThreshold: 2,
+				FailureThreshold: READINESS_FAILURE,
+			},
+		},
+		{
+			"grpc service", PROBE_STARTUP,
+			componentRel{Probes: map[string]probeRel{PROBE_STARTUP: {Type: PROBE_TYPE_GRPC, Service: "otg", Failure: 60}}}, 40051,
+			&corev1.Probe{
+				ProbeHandler:     corev1.ProbeHandler{GRPC: &corev1.GRPCAction{Port: 40051, Service: pointer.String("otg")}},
+				PeriodSeconds:    STARTUP_PERIOD,
+				FailureThreshold: 60,
+			},
+		},
+		{
+			"exec without port", PROBE_READINESS,
+			componentRel{Probes: map[string]probeRel{PROBE_READINESS: {Type: PROBE_TYPE_EXEC, Command: []string{"true"}}}}, 0,
+			&corev1.Probe{
+				ProbeHandler:     corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: []string{"true"}}},
+				PeriodSeconds:    READINESS_PERIOD,
+				FailureThreshold: READINESS_FAILURE,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildProbe(tt.kind, tt.comp, tt.port); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildProbe() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateProbes(t *testing.T) {
+	tests := []struct {
+		name    string
+		probes  map[string]probeRel
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"valid", map[string]probeRel{PROBE_READINESS: {Type: PROBE_TYPE_HTTP, Success: 2}, PROBE_STARTUP: {Type: PROBE_TYPE_TCP}}, false},
+		{"unknown probe", map[string]probeRel{"ready": {}}, true},
+		{"liveness success", map[string]probeRel{PROBE_LIVENESS: {Success: 2}}, true},
+		{"unknown type", map[string]probeRel{PROBE_LIVENESS: {Type: "udp"}}, true},
+		{"exec without command", map[string]probeRel{PROBE_LIVENESS: {Type: PROBE_TYPE_EXEC}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateProbes(componentRel{Name: "controller", Probes: tt.probes}); (err != nil) != tt.wantErr {
+				t.Errorf("validateProbes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
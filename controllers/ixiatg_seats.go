@@ -0,0 +1,172 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	networkv1beta1 "github.com/open-traffic-generator/keng-operator/api/v1beta1"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	STATE_QUEUED         string = "QUEUED"
+	DEFAULT_LICENSE_POOL string = "default"
+)
+
+// Serializes license seat admission across concurrent reconciles
+var seatLock sync.Mutex
+
+// ParseLicensePools parses comma separated <pool>=<seats> license pool capacities
+func ParseLicensePools(pools string) (map[string]int, error) {
+	seats := map[string]int{}
+	for _, pool := range strings.Split(pools, ",") {
+		pool = strings.TrimSpace(pool)
+		if pool == "" {
+			continue
+		}
+		parts := strings.SplitN(pool, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.New(fmt.Sprintf("Invalid license pool %s; expected <pool>=<seats>", pool))
+		}
+		count, err := strconv.Atoi(parts[1])
+		if err != nil || count < 0 {
+			return nil, errors.New(fmt.Sprintf("Invalid seats %s for license pool %s", parts[1], parts[0]))
+		}
+		seats[parts[0]] = count
+	}
+	return seats, nil
+}
+
+// licensePool returns the license pool the node takes a seat from; empty if not gated by seats
+func (r *IxiaTGReconciler) licensePool(ixia *networkv1beta1.IxiaTG) string {
+	if ixia.Spec.LicensePool != "" {
+		return ixia.Spec.LicensePool
+	}
+	if _, ok := r.LicensePools[DEFAULT_LICENSE_POOL]; ok {
+		return DEFAULT_LICENSE_POOL
+	}
+	return ""
+}
+
+func (r *IxiaTGReconciler) validateLicensePool(ixia *networkv1beta1.IxiaTG) error {
+	if ixia.Spec.LicensePool == "" {
+		return nil
+	}
+	if _, ok := r.LicensePools[ixia.Spec.LicensePool]; !ok {
+		return errors.New(fmt.Sprintf("License pool %s not configured in operator", ixia.Spec.LicensePool))
+	}
+	return nil
+}
+
+// holdsSeat reports whether the node holds a seat in the license pool; seats of failed, deleted or
+// undeployed nodes are free
+func holdsSeat(ixia *networkv1beta1.IxiaTG, pool string) bool {
+	return pool != "" && ixia.Status.LicenseSeat == pool && ixia.Status.State != STATE_FAILED &&
+		ixia.Spec.DesiredState == STATE_DEPLOYED && ixia.DeletionTimestamp.IsZero()
+}
+
+// admitLicenseSeat takes a seat in the license pool of the node, if available, recording it in status;
+// nodes waiting for seats are admitted in creation order. The returned reason is set if the node has to wait.
+func (r *IxiaTGReconciler) admitLicenseSeat(ctx context.Context, ixia *networkv1beta1.IxiaTG) (string, error) {
+	pool := r.licensePool(ixia)
+	if pool == "" || holdsSeat(ixia, pool) {
+		return "", nil
+	}
+	seats, ok := r.LicensePools[pool]
+	if !ok {
+		return fmt.Sprintf("License pool %s not configured in operator", pool), nil
+	}
+
+	seatLock.Lock()
+	defer seatLock.Unlock()
+	// Seats are counted from the API server, as the cache may not yet reflect seats just taken
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+	nodes := &networkv1beta1.IxiaTGList{}
+	if err := reader.List(ctx, nodes); err != nil {
+		return "", err
+	}
+	inUse := 0
+	waiting := []networkv1beta1.IxiaTG{*ixia}
+	for _, node := range nodes.Items {
+		if node.Namespace == ixia.Namespace && node.Name == ixia.Name {
+			continue
+		}
+		if holdsSeat(&node, pool) {
+			inUse++
+		} else if node.Status.State == STATE_QUEUED && node.DeletionTimestamp.IsZero() && r.licensePool(&node) == pool {
+			waiting = append(waiting, node)
+		}
+	}
+	sort.Slice(waiting, func(i, j int) bool {
+		if !waiting[i].CreationTimestamp.Equal(&waiting[j].CreationTimestamp) {
+			return waiting[i].CreationTimestamp.Before(&waiting[j].CreationTimestamp)
+		}
+		return waiting[i].Namespace+"/"+waiting[i].Name < waiting[j].Namespace+"/"+waiting[j].Name
+	})
+	position := 0
+	for index, node := range waiting {
+		if node.Namespace == ixia.Namespace && node.Name == ixia.Name {
+			position = index
+			break
+		}
+	}
+	if inUse+position >= seats {
+		return fmt.Sprintf("Waiting for a seat in license pool %s; %d of %d seats in use, %d ahead in queue", pool, inUse, seats, position), nil
+	}
+
+	ixia.Status.LicenseSeat = pool
+	ixia.Status.Reason = ""
+	if err := r.Status().Update(ctx, ixia); err != nil {
+		return "", err
+	}
+	log.Infof("Node %v in %v took a seat in license pool %s (%d of %d in use)", ixia.Name, ixia.Namespace, pool, inUse+1, seats)
+	return "", nil
+}
+
+// queuedNodes maps changes of any node to the nodes waiting for license seats, as seats may have been freed
+func (r *IxiaTGReconciler) queuedNodes(ctx context.Context, obj client.Object) []reconcile.Request {
+	if len(r.LicensePools) == 0 {
+		return nil
+	}
+	nodes := &networkv1beta1.IxiaTGList{}
+	if err := r.List(ctx, nodes); err != nil {
+		log.Errorf("Failed to get list of IxiaTG nodes - %v", err)
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, node := range nodes.Items {
+		if node.Status.State == STATE_QUEUED && (node.Name != obj.GetName() || node.Namespace != obj.GetNamespace()) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: node.Name, Namespace: node.Namespace}})
+		}
+	}
+	return requests
+}
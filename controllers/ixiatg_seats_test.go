@@ -0,0 +1,93 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseLicensePools(t *testing.T) {
+	tests := []struct {
+		name    string
+		pools   string
+		want    map[string]int
+		wantErr bool
+	}{
+		{"empty", "", map[string]int{}, false},
+		{"single", "default=2", map[string]int{"default": 2}, false},
+		{"multiple", " default=2 , lab=0,", map[string]int{"default": 2, "lab": 0}, false},
+		{"missing seats", "default", nil, true},
+		{"missing pool", "=2", nil, true},
+		{"invalid seats", "default=two", nil, true},
+		{"negative seats", "default=-1", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLicensePools(tt.pools)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLicensePools(%q) error = %v, wantErr %v", tt.pools, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLicensePools(%q) = %v, want %v", tt.pools, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdmitLicenseSeat(t *testing.T) {
+	seated := testNode("seated")
+	seated.Spec.DesiredState = STATE_DEPLOYED
+	seated.Status.State = STATE_DEPLOYED
+	seated.Status.LicenseSeat = DEFAULT_LICENSE_POOL
+	first := testNode("first")
+	first.Spec.DesiredState = STATE_DEPLOYED
+	first.Status.State = STATE_QUEUED
+	first.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Minute))
+	second := testNode("second")
+	second.Spec.DesiredState = STATE_DEPLOYED
+	second.CreationTimestamp = metav1.Now()
+
+	r := testReconciler(t, seated, first, second)
+	r.LicensePools = map[string]int{DEFAULT_LICENSE_POOL: 2}
+	ctx := context.Background()
+
+	// The remaining seat goes to the node queued first
+	reason, err := r.admitLicenseSeat(ctx, second)
+	if err != nil || !strings.Contains(reason, "1 ahead in queue") || second.Status.LicenseSeat != "" {
+		t.Errorf("admitLicenseSeat(second) reason %q, seat %q, err %v, want queued behind first", reason, second.Status.LicenseSeat, err)
+	}
+	reason, err = r.admitLicenseSeat(ctx, first)
+	if err != nil || reason != "" || first.Status.LicenseSeat != DEFAULT_LICENSE_POOL {
+		t.Errorf("admitLicenseSeat(first) reason %q, seat %q, err %v, want seat taken", reason, first.Status.LicenseSeat, err)
+	}
+
+	// Seats of undeployed nodes are freed
+	seated.Spec.DesiredState = ""
+	if err = r.Update(ctx, seated); err != nil {
+		t.Fatal(err)
+	}
+	reason, err = r.admitLicenseSeat(ctx, second)
+	if err != nil || reason != "" || second.Status.LicenseSeat != DEFAULT_LICENSE_POOL {
+		t.Errorf("admitLicenseSeat(second) reason %q, seat %q, err %v, want seat taken", reason, second.Status.LicenseSeat, err)
+	}
+}
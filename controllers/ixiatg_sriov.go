@@ -0,0 +1,198 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	networkv1beta1 "github.com/open-traffic-generator/keng-operator/api/v1beta1"
+)
+
+const (
+	DATA_PATH_AF_PACKET string = "af_packet"
+	DATA_PATH_SRIOV     string = "sriov"
+
+	DEFAULT_HUGEPAGE_SIZE string = "1Gi"
+	DEFAULT_HUGEPAGES     string = "2Gi"
+	HUGEPAGES_VOL_NAME    string = "hugepages"
+	HUGEPAGES_MOUNT_PATH  string = "/dev/hugepages"
+
+	// SR-IOV device plugin sets the allocated PCI addresses in PCIDEVICE_<resource name> environment variable
+	PCI_DEVICE_ENV_PREFIX string = "PCIDEVICE_"
+	OPT_NO_HUGEPAGES      string = "OPT_NO_HUGEPAGES"
+)
+
+var pciAddressRegexp = regexp.MustCompile(`^[0-9a-fA-F]{4}:[0-9a-fA-F]{2}:[0-9a-fA-F]{2}\.[0-7]$`)
+
+func validateDataPaths(ixia *networkv1beta1.IxiaTG) error {
+	for _, intf := range ixia.Spec.Interfaces {
+		switch intf.DataPath {
+		case "", DATA_PATH_AF_PACKET:
+			if intf.Resource != "" || intf.PCIAddress != "" {
+				return errors.New(fmt.Sprintf("Resource and PCI address of interface %s require %s data path", intf.Name, DATA_PATH_SRIOV))
+			}
+		case DATA_PATH_SRIOV:
+			if errs := validation.IsQualifiedName(intf.Resource); len(errs) > 0 {
+				return errors.New(fmt.Sprintf("Invalid resource %s of interface %s - %s", intf.Resource, intf.Name, strings.Join(errs, ", ")))
+			}
+			if intf.PCIAddress != "" && !pciAddressRegexp.MatchString(intf.PCIAddress) {
+				return errors.New(fmt.Sprintf("Invalid PCI address %s of interface %s", intf.PCIAddress, intf.Name))
+			}
+			// All devices of a resource are set in one environment variable, so which one is of the interface
+			// can only be told if a port pod requests the resource once
+			for _, other := range ixia.Spec.Interfaces {
+				if other.Name != intf.Name && other.DataPath == DATA_PATH_SRIOV && other.Resource == intf.Resource &&
+					portPodKey(other) == portPodKey(intf) && intf.PCIAddress == "" {
+					return errors.New(fmt.Sprintf("PCI address of interface %s is required, as resource %s is shared with interface %s", intf.Name, intf.Resource, other.Name))
+				}
+			}
+		default:
+			return errors.New(fmt.Sprintf("Unsupported data path %s of interface %s; expected %s or %s", intf.DataPath, intf.Name, DATA_PATH_AF_PACKET, DATA_PATH_SRIOV))
+		}
+	}
+	dpdk := ixia.Spec.DPDK
+	switch dpdk.HugepageSize {
+	case "", "2Mi", "1Gi":
+	default:
+		return errors.New(fmt.Sprintf("Unsupported hugepage size %s; expected 2Mi or 1Gi", dpdk.HugepageSize))
+	}
+	if dpdk.Hugepages != "" {
+		if _, err := resource.ParseQuantity(dpdk.Hugepages); err != nil {
+			return errors.New(fmt.Sprintf("Invalid hugepages %s - %v", dpdk.Hugepages, err))
+		}
+	}
+	return nil
+}
+
+// portPodKey returns the key of the port pod of an interface; interfaces of a group share a port pod
+func portPodKey(intf networkv1beta1.IxiaTGIntf) string {
+	if intf.Group != "" {
+		return "group/" + intf.Group
+	}
+	return "intf/" + intf.Name
+}
+
+// netdevCount returns the number of interfaces of a port pod present as network devices; SR-IOV interfaces
+// are bound to vfio for DPDK and are not
+func netdevCount(ixia *networkv1beta1.IxiaTG, intfList []string) int {
+	return len(intfList) - len(sriovInterfaces(ixia, intfList))
+}
+
+// sriovInterfaces returns the interfaces of a port pod using the SR-IOV data path
+func sriovInterfaces(ixia *networkv1beta1.IxiaTG, intfList []string) []networkv1beta1.IxiaTGIntf {
+	intfs := []networkv1beta1.IxiaTGIntf{}
+	for _, name := range intfList {
+		for _, intf := range ixia.Spec.Interfaces {
+			if intf.Name == name && intf.DataPath == DATA_PATH_SRIOV {
+				intfs = append(intfs, intf)
+			}
+		}
+	}
+	return intfs
+}
+
+// pciDeviceEnv returns the environment variable with PCI addresses allocated by the SR-IOV device plugin
+func pciDeviceEnv(res string) string {
+	return PCI_DEVICE_ENV_PREFIX + strings.ToUpper(strings.NewReplacer(".", "_", "/", "_", "-", "_").Replace(res))
+}
+
+// pciDeviceRef returns the reference to the PCI address allocated by the device plugin, expanded by the
+// traffic engine. Kubernetes only expands $(VAR) references to variables of the container spec, and the
+// device plugin variables are set by the kubelet after that expansion, so the reference is given in the
+// ${VAR} form, which Kubernetes leaves as is, for the traffic engine to expand at startup.
+func pciDeviceRef(res string) string {
+	return "${" + pciDeviceEnv(res) + "}"
+}
+
+// trafficIntfList returns the traffic engine interface list; SR-IOV interfaces are given by PCI address,
+// either specified or allocated by the device plugin, see pciDeviceRef, and others through af_packet. The
+// device plugin variable holds a single address as interfaces sharing a resource in a port pod require a
+// PCI address.
+func trafficIntfList(ixia *networkv1beta1.IxiaTG, intfList []string) string {
+	entries := []string{}
+	for _, name := range intfList {
+		entry := "virtual@af_packet," + name
+		for _, intf := range sriovInterfaces(ixia, []string{name}) {
+			if intf.PCIAddress != "" {
+				entry = "pci@" + intf.PCIAddress
+			} else {
+				entry = "pci@" + pciDeviceRef(intf.Resource)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return strings.Join(entries, " ")
+}
+
+func hugepages(ixia *networkv1beta1.IxiaTG) (corev1.ResourceName, resource.Quantity) {
+	size := ixia.Spec.DPDK.HugepageSize
+	if size == "" {
+		size = DEFAULT_HUGEPAGE_SIZE
+	}
+	amount := ixia.Spec.DPDK.Hugepages
+	if amount == "" {
+		amount = DEFAULT_HUGEPAGES
+	}
+	return corev1.ResourceName(corev1.ResourceHugePagesPrefix + size), resource.MustParse(amount)
+}
+
+// setSRIOVResources requests the SR-IOV device plugin resources and hugepages of the traffic engine and
+// mounts the hugepages; requests and limits of these resources must match. No hugepage environment is set:
+// DPDK finds the hugetlbfs mount itself and is bounded by the hugepages limit.
+func setSRIOVResources(container *corev1.Container, ixia *networkv1beta1.IxiaTG, intfs []networkv1beta1.IxiaTGIntf) {
+	counts := map[string]int64{}
+	for _, intf := range intfs {
+		counts[intf.Resource]++
+	}
+	limits := corev1.ResourceList{}
+	for res, count := range counts {
+		limits[corev1.ResourceName(res)] = *resource.NewQuantity(count, resource.DecimalSI)
+	}
+	name, amount := hugepages(ixia)
+	limits[name] = amount
+	if container.Resources.Requests == nil {
+		container.Resources.Requests = corev1.ResourceList{}
+	}
+	if container.Resources.Limits == nil {
+		container.Resources.Limits = corev1.ResourceList{}
+	}
+	for res, quantity := range limits {
+		container.Resources.Requests[res] = quantity
+		container.Resources.Limits[res] = quantity
+	}
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: HUGEPAGES_VOL_NAME, MountPath: HUGEPAGES_MOUNT_PATH})
+}
+
+// hugepagesVolume returns the hugepages backed volume of the traffic engine
+func hugepagesVolume(ixia *networkv1beta1.IxiaTG) corev1.Volume {
+	size := ixia.Spec.DPDK.HugepageSize
+	if size == "" {
+		size = DEFAULT_HUGEPAGE_SIZE
+	}
+	medium := corev1.StorageMedium(string(corev1.StorageMediumHugePages) + "-" + size)
+	return corev1.Volume{
+		Name:         HUGEPAGES_VOL_NAME,
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{Medium: medium}},
+	}
+}
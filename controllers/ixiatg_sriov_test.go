@@ -0,0 +1,158 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	networkv1beta1 "github.com/open-traffic-generator/keng-operator/api/v1beta1"
+)
+
+func sriovNode(intfs ...networkv1beta1.IxiaTGIntf) *networkv1beta1.IxiaTG {
+	ixia := testNode("otg")
+	ixia.Spec.Interfaces = intfs
+	return ixia
+}
+
+func TestValidateDataPaths(t *testing.T) {
+	sriov := func(name string, group string, pci string) networkv1beta1.IxiaTGIntf {
+		return networkv1beta1.IxiaTGIntf{Name: name, Group: group, DataPath: DATA_PATH_SRIOV, Resource: "intel.com/sriov_netdevice", PCIAddress: pci}
+	}
+	tests := []struct {
+		name    string
+		intfs   []networkv1beta1.IxiaTGIntf
+		dpdk    networkv1beta1.IxiaTGDPDK
+		wantErr bool
+	}{
+		{"af_packet", []networkv1beta1.IxiaTGIntf{{Name: "eth1"}, {Name: "eth2", DataPath: DATA_PATH_AF_PACKET}}, networkv1beta1.IxiaTGDPDK{}, false},
+		{"sriov", []networkv1beta1.IxiaTGIntf{sriov("eth1", "", "")}, networkv1beta1.IxiaTGDPDK{HugepageSize: "2Mi", Hugepages: "1Gi"}, false},
+		{"sriov with pci address", []networkv1beta1.IxiaTGIntf{sriov("eth1", "", "0000:3b:02.1")}, networkv1beta1.IxiaTGDPDK{}, false},
+		{"shared resource in separate pods", []networkv1beta1.IxiaTGIntf{sriov("eth1", "", ""), sriov("eth2", "", "")}, networkv1beta1.IxiaTGDPDK{}, false},
+		{"shared resource with pci addresses", []networkv1beta1.IxiaTGIntf{sriov("eth1", "g1", "0000:3b:02.1"), sriov("eth2", "g1", "0000:3b:02.2")}, networkv1beta1.IxiaTGDPDK{}, false},
+		{"shared resource without pci address", []networkv1beta1.IxiaTGIntf{sriov("eth1", "g1", "0000:3b:02.1"), sriov("eth2", "g1", "")}, networkv1beta1.IxiaTGDPDK{}, true},
+		{"resource with af_packet", []networkv1beta1.IxiaTGIntf{{Name: "eth1", Resource: "intel.com/sriov_netdevice"}}, networkv1beta1.IxiaTGDPDK{}, true},
+		{"pci address with af_packet", []networkv1beta1.IxiaTGIntf{{Name: "eth1", PCIAddress: "0000:3b:02.1"}}, networkv1beta1.IxiaTGDPDK{}, true},
+		{"sriov without resource", []networkv1beta1.IxiaTGIntf{{Name: "eth1", DataPath: DATA_PATH_SRIOV}}, networkv1beta1.IxiaTGDPDK{}, true},
+		{"invalid pci address", []networkv1beta1.IxiaTGIntf{sriov("eth1", "", "3b:02.1")}, networkv1beta1.IxiaTGDPDK{}, true},
+		{"unknown data path", []networkv1beta1.IxiaTGIntf{{Name: "eth1", DataPath: "xdp"}}, networkv1beta1.IxiaTGDPDK{}, true},
+		{"unknown hugepage size", []networkv1beta1.IxiaTGIntf{sriov("eth1", "", "")}, networkv1beta1.IxiaTGDPDK{HugepageSize: "4Mi"}, true},
+		{"invalid hugepages", []networkv1beta1.IxiaTGIntf{sriov("eth1", "", "")}, networkv1beta1.IxiaTGDPDK{Hugepages: "two"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ixia := sriovNode(tt.intfs...)
+			ixia.Spec.DPDK = tt.dpdk
+			if err := validateDataPaths(ixia); (err != nil) != tt.wantErr {
+				t.Errorf("validateDataPaths() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPCIDeviceEnv(t *testing.T) {
+	tests := []struct {
+		res  string
+		want string
+	}{
+		{"intel.com/sriov_netdevice", "PCIDEVICE_INTEL_COM_SRIOV_NETDEVICE"},
+		{"mellanox.com/cx5-vfs", "PCIDEVICE_MELLANOX_COM_CX5_VFS"},
+	}
+	for _, tt := range tests {
+		if got := pciDeviceEnv(tt.res); got != tt.want {
+			t.Errorf("pciDeviceEnv(%v) = %v, want %v", tt.res, got, tt.want)
+		}
+	}
+}
+
+func TestTrafficIntfList(t *testing.T) {
+	ixia := sriovNode(
+		networkv1beta1.IxiaTGIntf{Name: "eth1"},
+		networkv1beta1.IxiaTGIntf{Name: "eth2", DataPath: DATA_PATH_SRIOV, Resource: "intel.com/sriov_netdevice"},
+		networkv1beta1.IxiaTGIntf{Name: "eth3", DataPath: DATA_PATH_SRIOV, Resource: "intel.com/sriov_netdevice", PCIAddress: "0000:3b:02.1"},
+	)
+	tests := []struct {
+		name        string
+		intfList    []string
+		want        string
+		wantNetdevs int
+	}{
+		{"af_packet", []string{"eth1"}, "virtual@af_packet,eth1", 1},
+		{"device plugin address", []string{"eth2"}, "pci@${PCIDEVICE_INTEL_COM_SRIOV_NETDEVICE}", 0},
+		{"mixed", []string{"eth1", "eth3"}, "virtual@af_packet,eth1 pci@0000:3b:02.1", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trafficIntfList(ixia, tt.intfList); got != tt.want {
+				t.Errorf("trafficIntfList() = %v, want %v", got, tt.want)
+			}
+			if got := netdevCount(ixia, tt.intfList); got != tt.wantNetdevs {
+				t.Errorf("netdevCount() = %v, want %v", got, tt.wantNetdevs)
+			}
+		})
+	}
+}
+
+func TestSRIOVTrafficEngine(t *testing.T) {
+	ixia := sriovNode(networkv1beta1.IxiaTGIntf{Name: "eth1", DataPath: DATA_PATH_SRIOV, Resource: "intel.com/sriov_netdevice"})
+	ixia.Spec.Release = "test-sriov"
+	ixia.Spec.DPDK.HugepageSize = "2Mi"
+	ixia.Spec.DPDK.Hugepages = "512Mi"
+	r := testReconciler(t, ixia)
+	testRelease(t, r, ixia.Spec.Release)
+
+	var te *corev1.Container
+	containers := r.containersForIxia("otg-port-eth1", []string{"eth1"}, ixia)
+	for i := range containers {
+		if strings.HasSuffix(containers[i].Name, IMAGE_TRAFFIC_ENG) {
+			te = &containers[i]
+		}
+	}
+	if te == nil {
+		t.Fatalf("containersForIxia() = %v, want traffic engine", containers)
+	}
+	env := map[string]string{}
+	for _, e := range te.Env {
+		env[e.Name] = e.Value
+	}
+	// The engine expands the device plugin variable; Kubernetes leaves ${VAR} as is
+	if got := env["ARG_IFACE_LIST"]; got != "pci@${PCIDEVICE_INTEL_COM_SRIOV_NETDEVICE}" || strings.Contains(got, "$(") {
+		t.Errorf("ARG_IFACE_LIST = %v, want device plugin reference for the engine", got)
+	}
+	if _, ok := env[OPT_NO_HUGEPAGES]; ok {
+		t.Errorf("%s set for SR-IOV traffic engine", OPT_NO_HUGEPAGES)
+	}
+	for res, want := range map[corev1.ResourceName]string{"intel.com/sriov_netdevice": "1", "hugepages-2Mi": "512Mi"} {
+		limit, request := te.Resources.Limits[res], te.Resources.Requests[res]
+		if limit.Cmp(resource.MustParse(want)) != 0 || request.Cmp(limit) != 0 {
+			t.Errorf("resource %v request %v, limit %v, want %v", res, request.String(), limit.String(), want)
+		}
+	}
+	mounted := false
+	for _, m := range te.VolumeMounts {
+		mounted = mounted || (m.Name == HUGEPAGES_VOL_NAME && m.MountPath == HUGEPAGES_MOUNT_PATH)
+	}
+	if !mounted {
+		t.Errorf("traffic engine volume mounts %v, want hugepages at %v", te.VolumeMounts, HUGEPAGES_MOUNT_PATH)
+	}
+	if vol := hugepagesVolume(ixia); vol.EmptyDir == nil || vol.EmptyDir.Medium != "HugePages-2Mi" {
+		t.Errorf("hugepagesVolume() = %+v, want HugePages-2Mi medium", vol)
+	}
+}
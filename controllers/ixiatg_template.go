@@ -0,0 +1,170 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+
+	networkv1beta1 "github.com/open-traffic-generator/keng-operator/api/v1beta1"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Labels of generated pods selecting them into services, network policies and the operator cache
+var protectedPodLabels = []string{"app", "topo", NODE_LABEL}
+
+// validatePodTemplates verifies the pod templates in spec can be decoded as pod templates and leave the
+// name, namespace and selector labels of generated pods unchanged
+func validatePodTemplates(ixia *networkv1beta1.IxiaTG) error {
+	templates := map[string]*runtime.RawExtension{
+		"controller_pod_template": ixia.Spec.ControllerPodTemplate,
+		"port_pod_template":       ixia.Spec.PortPodTemplate,
+	}
+	for name, tmpl := range templates {
+		if tmpl == nil || len(tmpl.Raw) == 0 {
+			continue
+		}
+		podTmpl := corev1.PodTemplateSpec{}
+		if err := json.Unmarshal(tmpl.Raw, &podTmpl); err != nil {
+			return errors.New(fmt.Sprintf("Invalid %s - %v", name, err))
+		}
+		if podTmpl.Name != "" || podTmpl.Namespace != "" {
+			return errors.New(fmt.Sprintf("Invalid %s - name and namespace of generated pods cannot be changed", name))
+		}
+		for _, label := range protectedPodLabels {
+			if _, ok := podTmpl.Labels[label]; ok {
+				return errors.New(fmt.Sprintf("Invalid %s - label %s of generated pods cannot be changed", name, label))
+			}
+		}
+	}
+	return nil
+}
+
+// applyPodTemplate strategically merges the partial pod template onto the generated pod; containers
+// are matched by name, and for port pods also by component name without the pod name prefix
+func applyPodTemplate(pod *corev1.Pod, tmpl *runtime.RawExtension, contPrefix string) error {
+	if tmpl == nil || len(tmpl.Raw) == 0 {
+		return nil
+	}
+
+	patch := tmpl.Raw
+	if contPrefix != "" {
+		var err error
+		if patch, err = prefixTemplateContainers(pod, patch, contPrefix); err != nil {
+			return err
+		}
+	}
+
+	// Retain identity and selector labels of the generated pod
+	name := pod.Name
+	namespace := pod.Namespace
+	labels := pod.Labels
+
+	original, err := json.Marshal(corev1.PodTemplateSpec{ObjectMeta: pod.ObjectMeta, Spec: pod.Spec})
+	if err != nil {
+		return err
+	}
+	merged, err := strategicpatch.StrategicMergePatch(original, patch, corev1.PodTemplateSpec{})
+	if err != nil {
+		return errors.New(fmt.Sprintf("Failed to apply pod template on %s - %v", pod.Name, err))
+	}
+	podTmpl := corev1.PodTemplateSpec{}
+	if err = json.Unmarshal(merged, &podTmpl); err != nil {
+		return errors.New(fmt.Sprintf("Failed to apply pod template on %s - %v", pod.Name, err))
+	}
+
+	// Tolerations have no merge key in pod specs and would be replaced, so they are merged by key
+	if len(podTmpl.Spec.Tolerations) > 0 {
+		podTmpl.Spec.Tolerations = mergeTolerations(pod.Spec.Tolerations, podTmpl.Spec.Tolerations)
+	}
+
+	pod.ObjectMeta = podTmpl.ObjectMeta
+	pod.Spec = podTmpl.Spec
+	pod.Name = name
+	pod.Namespace = namespace
+	if pod.Labels == nil {
+		pod.Labels = map[string]string{}
+	}
+	for k, v := range labels {
+		pod.Labels[k] = v
+	}
+	log.Infof("Applied pod template on %s", pod.Name)
+	return nil
+}
+
+// mergeTolerations returns the generated tolerations with those of the template added, replacing any of
+// the same key
+func mergeTolerations(generated []corev1.Toleration, tmpl []corev1.Toleration) []corev1.Toleration {
+	merged := []corev1.Toleration{}
+	for _, gen := range generated {
+		found := false
+		for _, t := range tmpl {
+			if t.Key == gen.Key {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, gen)
+		}
+	}
+	return append(merged, tmpl...)
+}
+
+// prefixTemplateContainers renames template containers given by component name to the generated
+// container name, so a single port pod template applies to all port pods
+func prefixTemplateContainers(pod *corev1.Pod, patch []byte, contPrefix string) ([]byte, error) {
+	generated := map[string]bool{}
+	for _, c := range pod.Spec.Containers {
+		generated[c.Name] = true
+	}
+	for _, c := range pod.Spec.InitContainers {
+		generated[c.Name] = true
+	}
+
+	tmpl := map[string]interface{}{}
+	if err := json.Unmarshal(patch, &tmpl); err != nil {
+		return nil, err
+	}
+	spec, ok := tmpl["spec"].(map[string]interface{})
+	if !ok {
+		return patch, nil
+	}
+	for _, key := range []string{"containers", "initContainers"} {
+		conts, ok := spec[key].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, c := range conts {
+			cont, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := cont["name"].(string)
+			if name != "" && !generated[name] && generated[contPrefix+name] {
+				cont["name"] = contPrefix + name
+			}
+		}
+	}
+	return json.Marshal(tmpl)
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestValidatePodTemplates(t *testing.T) {
+	tests := []struct {
+		name    string
+		tmpl    string
+		wantErr bool
+	}{
+		{"labels and runtime class", `{"metadata": {"labels": {"team": "lab"}}, "spec": {"runtimeClassName": "kata"}}`, false},
+		{"invalid json", `{"spec": {"containers": [}`, true},
+		{"invalid field type", `{"spec": {"containers": "traffic-engine"}}`, true},
+		{"name", `{"metadata": {"name": "otg-port"}}`, true},
+		{"namespace", `{"metadata": {"namespace": "default"}}`, true},
+		{"selector label", `{"metadata": {"labels": {"app": "otg"}}}`, true},
+		{"node label", `{"metadata": {"labels": {"` + NODE_LABEL + `": "other"}}}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, port := range []bool{false, true} {
+				ixia := testNode("otg")
+				if port {
+					ixia.Spec.PortPodTemplate = &runtime.RawExtension{Raw: []byte(tt.tmpl)}
+				} else {
+					ixia.Spec.ControllerPodTemplate = &runtime.RawExtension{Raw: []byte(tt.tmpl)}
+				}
+				if err := validatePodTemplates(ixia); (err != nil) != tt.wantErr {
+					t.Errorf("validatePodTemplates() port %v error = %v, wantErr %v", port, err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
+
+// templatePod returns a generated port pod with traffic and protocol engine containers
+func templatePod() *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "otg-port-eth1",
+			Namespace: "ixia-c",
+			Labels:    map[string]string{"app": "otg-port-eth1", NODE_LABEL: "otg"},
+		},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "init-container", Image: "busybox"}},
+			Containers: []corev1.Container{
+				{Name: "otg-port-eth1-protocol-engine", Image: "protocol-engine", Env: []corev1.EnvVar{{Name: "INTF_LIST", Value: "eth1"}}},
+				{Name: "otg-port-eth1-traffic-engine", Image: "traffic-engine", Env: []corev1.EnvVar{
+					{Name: "ARG_IFACE_LIST", Value: "virtual@af_packet,eth1"},
+					{Name: "OPT_NO_HUGEPAGES", Value: "Yes"},
+				}},
+			},
+			Volumes:     []corev1.Volume{{Name: "config", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}},
+			Tolerations: []corev1.Toleration{{Key: "lab", Operator: corev1.TolerationOpExists}, {Key: "ixia-c", Operator: corev1.TolerationOpExists}},
+		},
+	}
+}
+
+func TestApplyPodTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		tmpl    string
+		prefix  string
+		wantErr bool
+		check   func(t *testing.T, pod *corev1.Pod)
+	}{
+		{"no template", "", "otg-port-eth1-", false, func(t *testing.T, pod *corev1.Pod) {
+			if !reflect.DeepEqual(pod, templatePod()) {
+				t.Errorf("applyPodTemplate() without template changed pod to %+v", pod)
+			}
+		}},
+		{"component container names", `{"spec": {"containers": [{"name": "traffic-engine", "env": [{"name": "OPT_NO_HUGEPAGES", "value": "No"}]}],
+			"initContainers": [{"name": "init-container", "image": "lab/busybox"}]}}`, "otg-port-eth1-", false, func(t *testing.T, pod *corev1.Pod) {
+			if len(pod.Spec.Containers) != 2 || pod.Spec.Containers[1].Name != "otg-port-eth1-traffic-engine" {
+				t.Fatalf("applyPodTemplate() containers %+v, want template merged into traffic engine", pod.Spec.Containers)
+			}
+			want := []corev1.EnvVar{{Name: "ARG_IFACE_LIST", Value: "virtual@af_packet,eth1"}, {Name: "OPT_NO_HUGEPAGES", Value: "No"}}
+			if !reflect.DeepEqual(pod.Spec.Containers[1].Env, want) {
+				t.Errorf("applyPodTemplate() env %v, want %v", pod.Spec.Containers[1].Env, want)
+			}
+			if pod.Spec.InitContainers[0].Image != "lab/busybox" {
+				t.Errorf("applyPodTemplate() init container image %v, want lab/busybox", pod.Spec.InitContainers[0].Image)
+			}
+		}},
+		{"generated container names", `{"spec": {"containers": [{"name": "otg-port-eth1-protocol-engine", "env": [{"name": "LOG_LEVEL", "value": "debug"}]}]}}`, "otg-port-eth1-", false, func(t *testing.T, pod *corev1.Pod) {
+			want := []corev1.EnvVar{{Name: "LOG_LEVEL", Value: "debug"}, {Name: "INTF_LIST", Value: "eth1"}}
+			if !reflect.DeepEqual(pod.Spec.Containers[0].Env, want) {
+				t.Errorf("applyPodTemplate() env %v, want %v", pod.Spec.Containers[0].Env, want)
+			}
+		}},
+		{"volumes, tolerations and labels", `{"metadata": {"labels": {"team": "lab"}},
+			"spec": {"volumes": [{"name": "certs", "secret": {"secretName": "lab-certs"}}],
+			"tolerations": [{"key": "dpdk", "operator": "Exists"}, {"key": "lab", "operator": "Equal", "value": "ixia-c"}], "runtimeClassName": "kata"}}`, "", false, func(t *testing.T, pod *corev1.Pod) {
+			if len(pod.Spec.Volumes) != 2 || pod.Spec.Volumes[1].Name != "config" || pod.Spec.Volumes[0].Name != "certs" {
+				t.Errorf("applyPodTemplate() volumes %+v, want certs merged with config", pod.Spec.Volumes)
+			}
+			wantTolerations := []corev1.Toleration{{Key: "ixia-c", Operator: corev1.TolerationOpExists}, {Key: "dpdk", Operator: corev1.TolerationOpExists}, {Key: "lab", Operator: corev1.TolerationOpEqual, Value: "ixia-c"}}
+			if !reflect.DeepEqual(pod.Spec.Tolerations, wantTolerations) {
+				t.Errorf("applyPodTemplate() tolerations %+v, want %+v", pod.Spec.Tolerations, wantTolerations)
+			}
+			if pod.Spec.RuntimeClassName == nil || *pod.Spec.RuntimeClassName != "kata" {
+				t.Errorf("applyPodTemplate() runtime class %v, want kata", pod.Spec.RuntimeClassName)
+			}
+			want := map[string]string{"app": "otg-port-eth1", NODE_LABEL: "otg", "team": "lab"}
+			if !reflect.DeepEqual(pod.Labels, want) {
+				t.Errorf("applyPodTemplate() labels %v, want %v", pod.Labels, want)
+			}
+		}},
+		{"invalid json", `{"spec": [}`, "otg-port-eth1-", true, nil},
+		{"invalid json without prefix", `{"spec": [}`, "", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := templatePod()
+			var tmpl *runtime.RawExtension
+			if tt.tmpl != "" {
+				tmpl = &runtime.RawExtension{Raw: []byte(tt.tmpl)}
+			}
+			err := applyPodTemplate(pod, tmpl, tt.prefix)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyPodTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				tt.check(t, pod)
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"path"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	networkv1beta1 "github.com/open-traffic-generator/keng-operator/api/v1beta1"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	TLS_VOL_NAME       string = "tls"
+	TLS_MOUNT_PATH     string = "/home/ixia-c/tls"
+	TLS_SECRET_SUFFIX  string = "-tls"
+	TLS_CA_SUFFIX      string = "-tls-ca"
+	TLS_CA_CERT_KEY    string = "ca.crt"
+	TLS_CA_PRIVATE_KEY string = "ca.key"
+	// Hash of the certificate and key on the controller pod template, rolling the controller on renewal
+	TLS_HASH_ANNOTATION string = "network.keysight.com/tls-hash"
+
+	// Controller and gNMI server arguments for the mounted certificate and key
+	CTRL_TLS_CERT_ARG string = "--tls-cert-file"
+	CTRL_TLS_KEY_ARG  string = "--tls-key-file"
+	GNMI_TLS_CERT_ARG string = "--server-cert"
+	GNMI_TLS_KEY_ARG  string = "--server-key"
+	GNMI_INSECURE_ARG string = "--insecure"
+
+	TLS_CA_VALIDITY   time.Duration = 10 * 365 * 24 * time.Hour
+	TLS_CERT_VALIDITY time.Duration = 365 * 24 * time.Hour
+	// Generated certificates are renewed this long before expiry
+	TLS_RENEW_BEFORE time.Duration = 30 * 24 * time.Hour
+	// Deployed nodes with TLS are rendered again this often, renewing generated certificates and picking up
+	// replaced ones, see renderHash
+	TLS_RECHECK_INTERVAL time.Duration = 24 * time.Hour
+)
+
+// validateTLS verifies the TLS configuration in spec
+func validateTLS(ixia *networkv1beta1.IxiaTG) error {
+	tls := ixia.Spec.TLS
+	if tls == nil {
+		return nil
+	}
+	if (tls.SecretRef == "") == !tls.Generate {
+		return errors.New("TLS must specify exactly one of secret_ref or generate")
+	}
+	return nil
+}
+
+// tlsSecretName returns the kubernetes.io/tls secret mounted into the controller; empty if TLS is not enabled
+func tlsSecretName(ixia *networkv1beta1.IxiaTG) string {
+	if ixia.Spec.TLS == nil {
+		return ""
+	} else if ixia.Spec.TLS.SecretRef != "" {
+		return ixia.Spec.TLS.SecretRef
+	}
+	return ixia.Name + TLS_SECRET_SUFFIX
+}
+
+// tlsVolume returns the volume of the TLS secret, limited to the certificate and key
+func tlsVolume(ixia *networkv1beta1.IxiaTG) corev1.Volume {
+	return corev1.Volume{
+		Name: TLS_VOL_NAME,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: tlsSecretName(ixia),
+				Items: []corev1.KeyToPath{
+					{Key: corev1.TLSCertKey, Path: corev1.TLSCertKey},
+					{Key: corev1.TLSPrivateKeyKey, Path: corev1.TLSPrivateKeyKey},
+				},
+			},
+		},
+	}
+}
+
+// setTLSArgs passes the mounted certificate and key to the controller and gNMI containers; gNMI no longer
+// runs insecure
+func setTLSArgs(cont *corev1.Container) {
+	certFile := path.Join(TLS_MOUNT_PATH, corev1.TLSCertKey)
+	keyFile := path.Join(TLS_MOUNT_PATH, corev1.TLSPrivateKeyKey)
+	cont.VolumeMounts = append(cont.VolumeMounts, corev1.VolumeMount{Name: TLS_VOL_NAME, ReadOnly: true, MountPath: TLS_MOUNT_PATH})
+	switch cont.Name {
+	case CONTROLLER_NAME:
+		cont.Args = append(append([]string{}, cont.Args...), CTRL_TLS_CERT_ARG, certFile, CTRL_TLS_KEY_ARG, keyFile)
+	case GNMI_NAME:
+		tlsArgs := []string{GNMI_TLS_CERT_ARG, certFile, GNMI_TLS_KEY_ARG, keyFile}
+		if len(cont.Command) > 0 {
+			cont.Command = append(removeString(append([]string{}, cont.Command...), GNMI_INSECURE_ARG), tlsArgs...)
+		} else {
+			cont.Args = append(removeString(append([]string{}, cont.Args...), GNMI_INSECURE_ARG), tlsArgs...)
+		}
+	}
+}
+
+// tlsHash returns the hash of the certificate and key in the TLS secret of the node; the controller and gNMI
+// server only read them at startup, so a renewed certificate has to roll the controller pod
+func (r *IxiaTGReconciler) tlsHash(ctx context.Context, ixia *networkv1beta1.IxiaTG) (string, error) {
+	name := tlsSecretName(ixia)
+	secret, err := r.GetSecret(ctx, name, ixia.Namespace)
+	if err != nil {
+		return "", err
+	} else if secret == nil {
+		return "", errors.New(fmt.Sprintf("TLS secret %s not found in %s", name, ixia.Namespace))
+	}
+	h := sha256.New()
+	h.Write(secret.Data[corev1.TLSCertKey])
+	h.Write(secret.Data[corev1.TLSPrivateKeyKey])
+	return hex.EncodeToString(h.Sum(nil))[:SPEC_HASH_LENGTH], nil
+}
+
+// tlsHosts returns the DNS names the controller certificate is issued for: its api endpoint services,
+// the hosts they are exposed on, and localhost
+func tlsHosts(ixia *networkv1beta1.IxiaTG) []string {
+	hosts := []string{"localhost"}
+	ctrlPodName := ixia.Name + CTRL_POD_NAME_SUFFIX
+	for name := range ixia.Spec.ApiEndPoint {
+		svc := "service-" + name + "-" + ctrlPodName
+		hosts = append(hosts, svc, svc+"."+ixia.Namespace, svc+"."+ixia.Namespace+".svc", svc+"."+ixia.Namespace+SERVICE_NAME_SUFFIX)
+	}
+	hosts = append(hosts, exposedHosts(ixia)...)
+	sort.Strings(hosts)
+	return hosts
+}
+
+// reconcileTLS verifies the TLS secret referenced in spec, or generates a per node CA and controller
+// certificate; generated certificates are reissued when about to expire or when the endpoints change
+func (r *IxiaTGReconciler) reconcileTLS(ctx context.Context, ixia *networkv1beta1.IxiaTG) error {
+	if ixia.Spec.TLS == nil {
+		return nil
+	}
+	name := tlsSecretName(ixia)
+	secret, err := r.GetSecret(ctx, name, ixia.Namespace)
+	if err != nil {
+		return err
+	}
+	if ixia.Spec.TLS.SecretRef != "" {
+		if secret == nil {
+			return errors.New(fmt.Sprintf("TLS secret %s not found in %s", name, ixia.Namespace))
+		} else if secret.Type != corev1.SecretTypeTLS {
+			return errors.New(fmt.Sprintf("TLS secret %s is of type %s; expected %s", name, secret.Type, corev1.SecretTypeTLS))
+		}
+		return nil
+	}
+
+	hosts := tlsHosts(ixia)
+	if secret != nil && certValid(secret.Data[corev1.TLSCertKey], hosts) {
+		return nil
+	}
+	caCert, caKey, err := r.tlsCA(ctx, ixia)
+	if err != nil {
+		return err
+	}
+	certPEM, keyPEM, err := issueCert(ixia.Name+CTRL_POD_NAME_SUFFIX, hosts, caCert, caKey)
+	if err != nil {
+		return err
+	}
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ixia.Namespace,
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certPEM,
+			corev1.TLSPrivateKeyKey: keyPEM,
+			TLS_CA_CERT_KEY:         pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw}),
+		},
+	}
+	if err = controllerutil.SetControllerReference(ixia, secret, r.Scheme); err != nil {
+		return err
+	}
+	log.Infof("Issuing controller certificate of %v for %v", ixia.Name, hosts)
+	return r.applyObject(ctx, secret)
+}
+
+// tlsCA returns the CA of the node, generating it if not present or about to expire
+func (r *IxiaTGReconciler) tlsCA(ctx context.Context, ixia *networkv1beta1.IxiaTG) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	name := ixia.Name + TLS_CA_SUFFIX
+	secret, err := r.GetSecret(ctx, name, ixia.Namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+	if secret != nil {
+		cert, key, err := parseCA(secret.Data[TLS_CA_CERT_KEY], secret.Data[TLS_CA_PRIVATE_KEY])
+		if err == nil && time.Until(cert.NotAfter) > TLS_RENEW_BEFORE {
+			return cert, key, nil
+		}
+		log.Infof("Renewing CA %s of %v", name, ixia.Name)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	template, err := certTemplate(ixia.Name+" CA", TLS_CA_VALIDITY)
+	if err != nil {
+		return nil, nil, err
+	}
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ixia.Namespace,
+		},
+		Data: map[string][]byte{
+			TLS_CA_CERT_KEY:    pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			TLS_CA_PRIVATE_KEY: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		},
+	}
+	if err = controllerutil.SetControllerReference(ixia, secret, r.Scheme); err != nil {
+		return nil, nil, err
+	}
+	if err = r.applyObject(ctx, secret); err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	return cert, key, err
+}
+
+func certTemplate(commonName string, validity time.Duration) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validity),
+	}, nil
+}
+
+// issueCert issues a server certificate for the hosts signed by the CA, PEM encoded with its key
+func issueCert(commonName string, hosts []string, caCert *x509.Certificate, caKey *ecdsa.PrivateKey) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	template, err := certTemplate(commonName, TLS_CERT_VALIDITY)
+	if err != nil {
+		return nil, nil, err
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	template.DNSNames = hosts
+	template.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+func parseCA(certPEM []byte, keyPEM []byte) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	keyBlock, _ := pem.Decode(keyPEM)
+	if certBlock == nil || keyBlock == nil {
+		return nil, nil, errors.New("Invalid CA certificate or key")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// certValid reports whether the PEM encoded certificate is issued for exactly the hosts and not about to expire
+func certValid(certPEM []byte, hosts []string) bool {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil || time.Until(cert.NotAfter) <= TLS_RENEW_BEFORE {
+		return false
+	}
+	names := append([]string{}, cert.DNSNames...)
+	sort.Strings(names)
+	if len(names) != len(hosts) {
+		return false
+	}
+	for index := range names {
+		if names[index] != hosts[index] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	networkv1beta1 "github.com/open-traffic-generator/keng-operator/api/v1beta1"
+)
+
+func TestTLSHash(t *testing.T) {
+	ixia := testNode("otg")
+	ixia.Spec.TLS = &networkv1beta1.IxiaTGTLS{Generate: true}
+	r := testReconciler(t, ixia)
+	ctx := context.Background()
+
+	if _, err := r.tlsHash(ctx, ixia); err == nil {
+		t.Errorf("tlsHash() of missing secret succeeded")
+	}
+	if err := r.reconcileTLS(ctx, ixia); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := r.tlsHash(ctx, ixia)
+	if err != nil || len(hash) != SPEC_HASH_LENGTH {
+		t.Fatalf("tlsHash() = %q, %v", hash, err)
+	}
+
+	// Renewing the certificate changes the hash rolling the controller
+	secret := &corev1.Secret{}
+	if err = r.Get(ctx, types.NamespacedName{Name: tlsSecretName(ixia), Namespace: ixia.Namespace}, secret); err != nil {
+		t.Fatal(err)
+	}
+	secret.Data[corev1.TLSCertKey] = []byte("renewed")
+	if err = r.Update(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	if renewed, err := r.tlsHash(ctx, ixia); err != nil || renewed == hash {
+		t.Errorf("tlsHash() after renewal = %q, %v, want hash other than %q", renewed, err, hash)
+	}
+}
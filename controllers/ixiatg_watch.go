@@ -0,0 +1,148 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	networkv1beta1 "github.com/open-traffic-generator/keng-operator/api/v1beta1"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	UPDATE_POLICY_NONE string = "none"
+	UPDATE_POLICY_AUTO string = "auto"
+)
+
+func validateUpdatePolicy(ixia *networkv1beta1.IxiaTG) error {
+	switch ixia.Spec.UpdatePolicy {
+	case "", UPDATE_POLICY_NONE, UPDATE_POLICY_AUTO:
+		return nil
+	}
+	return errors.New(fmt.Sprintf("Unsupported update policy %s; must be %s or %s", ixia.Spec.UpdatePolicy, UPDATE_POLICY_NONE, UPDATE_POLICY_AUTO))
+}
+
+// autoUpdate reports whether existing generated objects of an IxiaTG are updated, or recreated, when drifted
+// from their spec, with update policy auto; by default they are frozen and only missing objects are created
+func autoUpdate(ixia *networkv1beta1.IxiaTG) bool {
+	return ixia.Spec.UpdatePolicy == UPDATE_POLICY_AUTO
+}
+
+// replicatedSecrets returns the secrets in the operator namespace replicated into topology namespaces
+func (r *IxiaTGReconciler) replicatedSecrets() []string {
+	secrets := []string{LIC_SERVER_SECRET}
+	for _, name := range append(append([]string{}, r.ImagePullSecrets...), r.ReplicatedSecrets...) {
+		if !containsString(secrets, name) {
+			secrets = append(secrets, name)
+		}
+	}
+	return secrets
+}
+
+// refreshRelInfo reloads the release info cached from the release configmap; releases located through
+// the release server are retained
+func (r *IxiaTGReconciler) refreshRelInfo(ctx context.Context, cfgMap *corev1.ConfigMap) {
+	data := []byte(cfgMap.Data["versions"])
+	var rel pubRel
+	if err := json.Unmarshal(data, &rel); err != nil || rel.Release == "" {
+		log.Errorf("Failed to parse release configmap %s - %v", cfgMap.Name, err)
+		return
+	}
+	if hasRelDep(rel.Release) && relDep(rel.Release).Source != DS_CONFIGMAP {
+		return
+	}
+	if err := r.loadRelInfo(ctx, rel.Release, &data, false, DS_CONFIGMAP, cfgMap.Namespace); err != nil {
+		log.Errorf("Failed to refresh release %s from configmap %s - %v", rel.Release, cfgMap.Name, err)
+	}
+}
+
+// allNodes returns reconcile requests for all IxiaTG nodes in the given namespaces, or the cluster if none
+func (r *IxiaTGReconciler) allNodes(ctx context.Context, namespaces ...string) []reconcile.Request {
+	nodes := &networkv1beta1.IxiaTGList{}
+	opts := []client.ListOption{}
+	for _, ns := range namespaces {
+		opts = append(opts, client.InNamespace(ns))
+	}
+	if err := r.List(ctx, nodes, opts...); err != nil {
+		log.Errorf("Failed to get list of IxiaTG nodes - %v", err)
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, node := range nodes.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: node.Name, Namespace: node.Namespace}})
+	}
+	return requests
+}
+
+// nodesForReleaseConfig refreshes the release info cache on release configmap changes and maps them to all
+// IxiaTG nodes
+func (r *IxiaTGReconciler) nodesForReleaseConfig(ctx context.Context, obj client.Object) []reconcile.Request {
+	if obj.GetNamespace() != CONFIG_MAP_NAMESPACE || obj.GetName() != CONFIG_MAP_NAME {
+		return nil
+	}
+	if cfgMap, ok := obj.(*corev1.ConfigMap); ok {
+		log.Infof("Release configmap %s changed, refreshing release info", cfgMap.Name)
+		r.refreshRelInfo(ctx, cfgMap)
+	}
+	return r.allNodes(ctx)
+}
+
+// nodesForOperatorSecret maps changes of replicated secrets in the operator namespace to all IxiaTG nodes,
+// so they are re-synced into every topology namespace, and changes of their replicas to the nodes using them
+func (r *IxiaTGReconciler) nodesForOperatorSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	if _, ok := obj.GetLabels()[REPLICATED_FROM_LABEL]; ok {
+		return r.allNodes(ctx, obj.GetNamespace())
+	}
+	if obj.GetNamespace() != CURRENT_NAMESPACE || !containsString(r.replicatedSecrets(), obj.GetName()) {
+		return nil
+	}
+	log.Infof("Secret %s changed, re-syncing topology namespaces", obj.GetName())
+	return r.allNodes(ctx)
+}
+
+// CacheByObject narrows the watched secrets and configmaps to those of the operator namespace, replicated
+// secrets and configmaps generated for IxiaTG nodes, and the watched pods to those generated for nodes;
+// other secrets, e.g. referenced in spec, are read directly from the API server
+func CacheByObject() map[client.Object]cache.ByObject {
+	exists := func(key string) labels.Selector {
+		req, _ := labels.NewRequirement(key, selection.Exists, nil)
+		return labels.NewSelector().Add(*req)
+	}
+	return map[client.Object]cache.ByObject{
+		&corev1.Secret{}: {Namespaces: map[string]cache.Config{
+			CURRENT_NAMESPACE:   {LabelSelector: labels.Everything()},
+			cache.AllNamespaces: {LabelSelector: exists(REPLICATED_FROM_LABEL)},
+		}},
+		&corev1.ConfigMap{}: {Namespaces: map[string]cache.Config{
+			CONFIG_MAP_NAMESPACE: {LabelSelector: labels.Everything()},
+			cache.AllNamespaces:  {LabelSelector: exists(NODE_LABEL)},
+		}},
+		&corev1.Pod{}: {Label: exists(NODE_LABEL)},
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestCacheByObject(t *testing.T) {
+	byObject := map[string]cache.ByObject{}
+	for obj, by := range CacheByObject() {
+		switch obj.(type) {
+		case *corev1.Secret:
+			byObject["Secret"] = by
+		case *corev1.ConfigMap:
+			byObject["ConfigMap"] = by
+		case *corev1.Pod:
+			byObject["Pod"] = by
+		}
+	}
+	tests := []struct {
+		name      string
+		kind      string
+		namespace string
+		labels    labels.Set
+		want      bool
+	}{
+		{"operator secret", "Secret", CURRENT_NAMESPACE, nil, true},
+		{"replicated secret", "Secret", "ixia-c", labels.Set{REPLICATED_FROM_LABEL: CURRENT_NAMESPACE + ".license-server"}, true},
+		{"user secret", "Secret", "ixia-c", nil, false},
+		{"release configmap", "ConfigMap", CONFIG_MAP_NAMESPACE, nil, true},
+		{"controller configmap", "ConfigMap", "ixia-c", labels.Set{NODE_LABEL: "otg"}, true},
+		{"user configmap", "ConfigMap", "ixia-c", nil, false},
+		{"generated pod", "Pod", "ixia-c", labels.Set{NODE_LABEL: "otg", "app": "otg-controller"}, true},
+		{"user pod", "Pod", "ixia-c", labels.Set{"app": "arista1"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			by, ok := byObject[tt.kind]
+			if !ok {
+				t.Fatalf("no cache selector for %s", tt.kind)
+			}
+			selector := by.Label
+			if by.Namespaces != nil {
+				cfg, ok := by.Namespaces[tt.namespace]
+				if !ok {
+					cfg = by.Namespaces[cache.AllNamespaces]
+				}
+				selector = cfg.LabelSelector
+			}
+			if got := selector.Matches(tt.labels); got != tt.want {
+				t.Errorf("%s selector %v matches %v = %v, want %v", tt.kind, selector, tt.labels, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodesForOperatorSecret(t *testing.T) {
+	ixia := testNode("otg", "eth1")
+	r := testReconciler(t, ixia)
+	r.ImagePullSecrets = []string{"artifactory"}
+	secret := func(name string, namespace string, lbls map[string]string) client.Object {
+		s := &corev1.Secret{}
+		s.Name, s.Namespace, s.Labels = name, namespace, lbls
+		return s
+	}
+	tests := []struct {
+		name   string
+		secret client.Object
+		want   int
+	}{
+		{"replicated secret", secret("artifactory", CURRENT_NAMESPACE, nil), 1},
+		{"replica", secret("artifactory", ixia.Namespace, map[string]string{REPLICATED_FROM_LABEL: CURRENT_NAMESPACE + ".artifactory"}), 1},
+		{"other operator secret", secret("webhook", CURRENT_NAMESPACE, nil), 0},
+		{"user secret", secret("lic", ixia.Namespace, nil), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.nodesForOperatorSecret(t.Context(), tt.secret); len(got) != tt.want {
+				t.Errorf("nodesForOperatorSecret() = %v, want %d requests", got, tt.want)
+			}
+		})
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"io/ioutil"
 	"os"
 	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -32,6 +33,7 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
@@ -61,6 +63,16 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var imagePullSecrets string
+	var registryRewrites string
+	var securityProfile string
+	var deployTimeout time.Duration
+	var restartThreshold int
+	var maxConcurrentReconciles int
+	var createParallelism int
+	var sharedLicenseServer bool
+	var licensePools string
+	var replicateSecrets string
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -72,6 +84,26 @@ func main() {
 		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&imagePullSecrets, "image-pull-secrets", "",
+		"Comma separated image pull secrets in the operator namespace, replicated to and used by all generated pods.")
+	flag.StringVar(&replicateSecrets, "replicate-secrets", "",
+		"Comma separated secrets in the operator namespace, e.g. TLS, replicated to all namespaces with an IxiaTG.")
+	flag.StringVar(&registryRewrites, "registry-rewrite", "",
+		"Comma separated <from>=<to> image prefix rewrite rules, e.g. ghcr.io/open-traffic-generator/=registry.lab/keng/")
+	flag.StringVar(&securityProfile, "default-security-profile", controllers.SECURITY_PRIVILEGED,
+		"Security profile of traffic and protocol engine containers when not specified in IxiaTG, either privileged or unprivileged.")
+	flag.DurationVar(&deployTimeout, "deploy-timeout", controllers.DEFAULT_DEPLOY_TIMEOUT,
+		"Deadline for all pods of an IxiaTG to be ready, after which it is marked FAILED with the blocking reasons.")
+	flag.IntVar(&restartThreshold, "restart-threshold", int(controllers.DEFAULT_RESTART_THRESHOLD),
+		"Restarts of a crash looping container after which its IxiaTG is marked FAILED.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"Maximum number of IxiaTG nodes reconciled concurrently.")
+	flag.IntVar(&createParallelism, "create-parallelism", controllers.DEFAULT_CREATE_PARALLELISM,
+		"Maximum number of port pods, with their services, created concurrently for an IxiaTG.")
+	flag.BoolVar(&sharedLicenseServer, "shared-license-server", false,
+		"If set, a single license server is deployed in the operator namespace and used by all IxiaTGs instead of one per controller.")
+	flag.StringVar(&licensePools, "license-pools", "",
+		"Comma separated <pool>=<seats> license pools; IxiaTGs exceeding the seats of their pool are QUEUED until a seat is freed.")
 	opts := zap.Options{
 		Development: false,
 	}
@@ -130,16 +162,43 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "b867187a.keysight.com",
+		Cache:                  cache.Options{ByObject: controllers.CacheByObject()},
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	rewrites, err := controllers.ParseRegistryRewrites(registryRewrites)
+	if err != nil {
+		setupLog.Error(err, "unable to parse registry rewrite rules")
+		os.Exit(1)
+	}
+	pools, err := controllers.ParseLicensePools(licensePools)
+	if err != nil {
+		setupLog.Error(err, "unable to parse license pools")
+		os.Exit(1)
+	}
+	if securityProfile != controllers.SECURITY_PRIVILEGED && securityProfile != controllers.SECURITY_UNPRIVILEGED {
+		setupLog.Error(fmt.Errorf("unsupported security profile %s", securityProfile), "unable to set default security profile")
+		os.Exit(1)
+	}
+
 	if err = (&controllers.IxiaTGReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("IxiaTG"),
-		Scheme: mgr.GetScheme(),
+		Client:                  mgr.GetClient(),
+		Log:                     ctrl.Log.WithName("controllers").WithName("IxiaTG"),
+		Scheme:                  mgr.GetScheme(),
+		ImagePullSecrets:        splitNames(imagePullSecrets),
+		ReplicatedSecrets:       splitNames(replicateSecrets),
+		RegistryRewrites:        rewrites,
+		DefaultSecurityProfile:  securityProfile,
+		DeployTimeout:           deployTimeout,
+		RestartThreshold:        int32(restartThreshold),
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+		CreateParallelism:       createParallelism,
+		SharedLicenseServer:     sharedLicenseServer,
+		LicensePools:            pools,
+		APIReader:               mgr.GetAPIReader(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "IxiaTG")
 		os.Exit(1)
@@ -167,3 +226,14 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// splitNames splits comma separated names, ignoring empty entries
+func splitNames(names string) []string {
+	list := []string{}
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			list = append(list, name)
+		}
+	}
+	return list
+}
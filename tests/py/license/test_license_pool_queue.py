@@ -0,0 +1,87 @@
+import pytest
+import utils
+import time
+
+@pytest.mark.license
+def test_license_pool_queue():
+    """
+    Restart operator with a single seat in the default license pool,
+    Deploy dut kne topology,
+    - namespace - 1: ixia-c
+    - namespace - 2: ixia-c-alt
+    Delete dut kne topology,
+    - namespace - 1: ixia-c
+    - namespace - 2: ixia-c-alt
+    Validate,
+    - first ixiatg DEPLOYED, second ixiatg QUEUED without any pods
+    - second ixiatg DEPLOYED once first is deleted
+    - operator pod health
+    """
+    namespace1 = 'ixia-c'
+    namespace1_config = 'ixia_c_rest_topology.yaml'
+    namespace2 = 'ixia-c-alt'
+    namespace2_config = 'ixia_c_alt_rest_topology.yaml'
+    name = 'otg'
+    expected_pods = [
+        'arista1',
+        'otg-controller',
+        'otg-port-eth1',
+        'otg-port-eth2',
+    ]
+
+    try:
+        utils.set_operator_args(['--license-pools=default=1'])
+        op_rscount = utils.get_operator_restart_count()
+        print("[Namespace:{}]Deploying KNE topology".format(
+            namespace1
+        ))
+        utils.create_kne_config(namespace1_config, namespace1)
+        utils.ixia_c_pods_ok(namespace1, expected_pods)
+        utils.ixiatg_state_ok(namespace1, name, 'DEPLOYED')
+
+        print("[Namespace:{}]Deploying KNE topology".format(
+            namespace2
+        ))
+        utils.create_kne_config(namespace2_config, namespace2, False)
+        utils.ixiatg_state_ok(namespace2, name, 'QUEUED', timeout_seconds=120)
+        reason = utils.get_ixiatg_reason(namespace2, name)
+        assert 'license pool default' in reason, \
+            "Unexpected queued reason {}".format(reason)
+        utils.ixia_c_pods_ok(namespace2, ['arista1'])
+        op_rscount = utils.ixia_c_operator_ok(op_rscount)
+
+        print("[Namespace:{}]Deleting KNE topology".format(
+            namespace1
+        ))
+        utils.delete_kne_config(namespace1_config, namespace1)
+        utils.ixia_c_pods_ok(namespace1, [])
+        utils.ixiatg_state_ok(namespace2, name, 'DEPLOYED')
+        utils.ixia_c_pods_ok(namespace2, expected_pods)
+        op_rscount = utils.ixia_c_operator_ok(op_rscount)
+
+        print("[Namespace:{}]Deleting KNE topology".format(
+            namespace2
+        ))
+        utils.delete_kne_config(namespace2_config, namespace2)
+        utils.ixia_c_pods_ok(namespace2, [])
+        op_rscount = utils.ixia_c_operator_ok(op_rscount)
+
+    finally:
+        utils.delete_kne_config(namespace1_config, namespace1)
+        utils.ixia_c_pods_ok(namespace1, [])
+
+        utils.delete_kne_config(namespace2_config, namespace2)
+        utils.ixia_c_pods_ok(namespace2, [])
+
+        utils.set_operator_args()
+        utils.wait_for(
+            lambda: utils.topology_deleted(namespace1),
+            'topology deleted',
+            timeout_seconds=30
+        )
+        utils.wait_for(
+            lambda: utils.topology_deleted(namespace2),
+            'topology deleted',
+            timeout_seconds=30
+        )
+        time.sleep(5)
@@ -0,0 +1,70 @@
+import pytest
+import utils
+import time
+
+@pytest.mark.miscellaneous
+def test_image_digest():
+    """
+    Deploy pd kne topology with image digests,
+    - namespace - 1: ixia-c
+    Delete pd kne topology,
+    - namespace - 1: ixia-c
+    Validate,
+    - digest pins the image instead of tag
+    - digest without algorithm defaults to sha256
+    - images without digest referenced by tag
+    """
+    namespace1 = 'ixia-c'
+    namespace1_config = 'ixia_c_pd_topology.yaml'
+    expected_pods = [
+        'otg-controller',
+        'otg-port-eth1',
+        'arista1'
+    ]
+    container_extensions = [
+        '-protocol-engine',
+        '-traffic-engine'
+    ]
+    digest = 'a' * 64
+    image_params = {
+        'controller': {'digest': 'sha256:' + digest},
+        'traffic-engine': {'digest': digest}
+    }
+    try:
+        op_rscount = utils.get_operator_restart_count()
+        print("[Namespace:{}]Deploying KNE topology".format(
+            namespace1
+        ))
+        utils.load_image_configmap(image_params)
+        utils.create_kne_config(namespace1_config, namespace1)
+        # digests do not exist in registry, only pods creation is verified
+        utils.ixia_c_pods_ok(namespace1, expected_pods, health=False)
+        utils.check_image('ixia-c', expected_pods[0], namespace1,
+                          'ghcr.io/open-traffic-generator/keng-controller@sha256:' + digest)
+        utils.check_image('gnmi', expected_pods[0], namespace1,
+                          'ghcr.io/open-traffic-generator/otg-gnmi-server:1.14.14')
+        utils.check_image(expected_pods[1]+container_extensions[0], expected_pods[1], namespace1,
+                          'ghcr.io/open-traffic-generator/ixia-c-protocol-engine:1.00.0.399')
+        utils.check_image(expected_pods[1]+container_extensions[1], expected_pods[1], namespace1,
+                          'ghcr.io/open-traffic-generator/ixia-c-traffic-engine@sha256:' + digest)
+        op_rscount = utils.ixia_c_operator_ok(op_rscount)
+
+        print("[Namespace:{}]Deleting KNE topology".format(
+            namespace1
+        ))
+        utils.delete_kne_config(namespace1_config, namespace1)
+        utils.ixia_c_pods_ok(namespace1, [])
+        op_rscount = utils.ixia_c_operator_ok(op_rscount)
+        utils.reset_configmap()
+
+    finally:
+        utils.delete_kne_config(namespace1_config, namespace1)
+        utils.ixia_c_pods_ok(namespace1, [])
+        utils.reset_configmap()
+
+        utils.wait_for(
+            lambda: utils.topology_deleted(namespace1),
+            'topology deleted',
+            timeout_seconds=30
+        )
+        time.sleep(5)
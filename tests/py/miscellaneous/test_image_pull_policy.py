@@ -0,0 +1,60 @@
+import pytest
+import utils
+import time
+
+@pytest.mark.miscellaneous
+def test_image_pull_policy():
+    """
+    Deploy pd kne topology with custom pull policy,
+    - namespace - 1: ixia-c
+    Delete pd kne topology,
+    - namespace - 1: ixia-c
+    Validate,
+    - configmap pull policy for controller and traffic engine
+    - default pull policy for other containers
+    """
+    namespace1 = 'ixia-c'
+    namespace1_config = 'ixia_c_pd_topology.yaml'
+    expected_pods = [
+        'otg-controller',
+        'otg-port-eth1',
+        'arista1'
+    ]
+    container_extensions = [
+        '-protocol-engine',
+        '-traffic-engine'
+    ]
+    image_params = {'controller': {'pull-policy': 'Always'}, 'traffic-engine': {'pull-policy': 'Always'}}
+    try:
+        op_rscount = utils.get_operator_restart_count()
+        print("[Namespace:{}]Deploying KNE topology".format(
+            namespace1
+        ))
+        utils.load_image_configmap(image_params)
+        utils.create_kne_config(namespace1_config, namespace1)
+        utils.ixia_c_pods_ok(namespace1, expected_pods)
+        utils.check_pull_policy('ixia-c', expected_pods[0], namespace1, 'Always')
+        utils.check_pull_policy('gnmi', expected_pods[0], namespace1, 'IfNotPresent')
+        utils.check_pull_policy(expected_pods[1]+container_extensions[0], expected_pods[1], namespace1, 'IfNotPresent')
+        utils.check_pull_policy(expected_pods[1]+container_extensions[1], expected_pods[1], namespace1, 'Always')
+        op_rscount = utils.ixia_c_operator_ok(op_rscount)
+
+        print("[Namespace:{}]Deleting KNE topology".format(
+            namespace1
+        ))
+        utils.delete_kne_config(namespace1_config, namespace1)
+        utils.ixia_c_pods_ok(namespace1, [])
+        op_rscount = utils.ixia_c_operator_ok(op_rscount)
+        utils.reset_configmap()
+
+    finally:
+        utils.delete_kne_config(namespace1_config, namespace1)
+        utils.ixia_c_pods_ok(namespace1, [])
+        utils.reset_configmap()
+
+        utils.wait_for(
+            lambda: utils.topology_deleted(namespace1),
+            'topology deleted',
+            timeout_seconds=30
+        )
+        time.sleep(5)
@@ -0,0 +1,57 @@
+import pytest
+import utils
+import time
+
+@pytest.mark.miscellaneous
+def test_listen_any_address():
+    """
+    Deploy pd kne topology,
+    - namespace - 1: ixia-c
+    Delete pd kne topology,
+    - namespace - 1: ixia-c
+    Validate,
+    - controller, gNMI server, traffic and protocol engines listen on
+      "::", so that they are reachable on IPv6-only and dual-stack clusters
+    """
+    namespace1 = 'ixia-c'
+    namespace1_config = 'ixia_c_pd_topology.yaml'
+    expected_pods = [
+        'otg-controller',
+        'otg-port-eth1',
+        'arista1'
+    ]
+    listeners = [
+        ('ixia-c', expected_pods[0], 8443),
+        ('gnmi', expected_pods[0], 50051),
+        (expected_pods[1] + '-traffic-engine', expected_pods[1], 5555),
+        (expected_pods[1] + '-protocol-engine', expected_pods[1], 50071),
+    ]
+    try:
+        op_rscount = utils.get_operator_restart_count()
+        print("[Namespace:{}]Deploying KNE topology".format(
+            namespace1
+        ))
+        utils.create_kne_config(namespace1_config, namespace1)
+        utils.ixia_c_pods_ok(namespace1, expected_pods)
+        for cont, pod, port in listeners:
+            assert utils.listening_on_any(cont, pod, namespace1, port), \
+                "Container {} of {} not listening on [::]:{}".format(cont, pod, port)
+        op_rscount = utils.ixia_c_operator_ok(op_rscount)
+
+        print("[Namespace:{}]Deleting KNE topology".format(
+            namespace1
+        ))
+        utils.delete_kne_config(namespace1_config, namespace1)
+        utils.ixia_c_pods_ok(namespace1, [])
+        op_rscount = utils.ixia_c_operator_ok(op_rscount)
+
+    finally:
+        utils.delete_kne_config(namespace1_config, namespace1)
+        utils.ixia_c_pods_ok(namespace1, [])
+
+        utils.wait_for(
+            lambda: utils.topology_deleted(namespace1),
+            'topology deleted',
+            timeout_seconds=30
+        )
+        time.sleep(5)
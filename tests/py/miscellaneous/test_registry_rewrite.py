@@ -0,0 +1,69 @@
+import pytest
+import utils
+import time
+
+@pytest.mark.miscellaneous
+def test_registry_rewrite():
+    """
+    Restart operator with registry rewrite rules,
+    Deploy pd kne topology,
+    - namespace - 1: ixia-c
+    Delete pd kne topology,
+    - namespace - 1: ixia-c
+    Validate,
+    - images rewritten by the first matching rule
+    - images without matching rule unchanged
+    """
+    namespace1 = 'ixia-c'
+    namespace1_config = 'ixia_c_pd_topology.yaml'
+    expected_pods = [
+        'otg-controller',
+        'otg-port-eth1',
+        'arista1'
+    ]
+    container_extensions = [
+        '-protocol-engine',
+        '-traffic-engine'
+    ]
+    rewrites = [
+        '--registry-rewrite='
+        'ghcr.io/open-traffic-generator/keng-=registry.lab/keng/keng-,'
+        'ghcr.io/open-traffic-generator/ixia-c-=mirror.lab/otg/ixia-c-'
+    ]
+    try:
+        utils.set_operator_args(rewrites)
+        op_rscount = utils.get_operator_restart_count()
+        print("[Namespace:{}]Deploying KNE topology".format(
+            namespace1
+        ))
+        utils.create_kne_config(namespace1_config, namespace1)
+        # rewritten images are not pullable, only pods creation is verified
+        utils.ixia_c_pods_ok(namespace1, expected_pods, health=False)
+        utils.check_image('ixia-c', expected_pods[0], namespace1,
+                          'registry.lab/keng/keng-controller:1.13.0-1')
+        utils.check_image('gnmi', expected_pods[0], namespace1,
+                          'ghcr.io/open-traffic-generator/otg-gnmi-server:1.14.14')
+        utils.check_image(expected_pods[1]+container_extensions[0], expected_pods[1], namespace1,
+                          'mirror.lab/otg/ixia-c-protocol-engine:1.00.0.399')
+        utils.check_image(expected_pods[1]+container_extensions[1], expected_pods[1], namespace1,
+                          'mirror.lab/otg/ixia-c-traffic-engine:1.8.0.25')
+        op_rscount = utils.ixia_c_operator_ok(op_rscount)
+
+        print("[Namespace:{}]Deleting KNE topology".format(
+            namespace1
+        ))
+        utils.delete_kne_config(namespace1_config, namespace1)
+        utils.ixia_c_pods_ok(namespace1, [])
+        op_rscount = utils.ixia_c_operator_ok(op_rscount)
+
+    finally:
+        utils.delete_kne_config(namespace1_config, namespace1)
+        utils.ixia_c_pods_ok(namespace1, [])
+        utils.set_operator_args()
+
+        utils.wait_for(
+            lambda: utils.topology_deleted(namespace1),
+            'topology deleted',
+            timeout_seconds=30
+        )
+        time.sleep(5)
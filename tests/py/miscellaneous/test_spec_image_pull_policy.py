@@ -0,0 +1,76 @@
+import pytest
+import utils
+import time
+
+@pytest.mark.miscellaneous
+def test_spec_image_pull_policy():
+    """
+    Deploy pd kne topology with custom pull policy,
+    - namespace - 1: ixia-c
+    Update ixiatg spec image pull policy,
+    - namespace - 1: ixia-c
+    Delete pd kne topology,
+    - namespace - 1: ixia-c
+    Validate,
+    - spec "*" pull policy overrides configmap pull policy
+    - spec component pull policy overrides spec "*" pull policy
+    """
+    namespace1 = 'ixia-c'
+    namespace1_config = 'ixia_c_pd_topology.yaml'
+    expected_pods = [
+        'otg-controller',
+        'otg-port-eth1',
+        'arista1'
+    ]
+    container_extensions = [
+        '-protocol-engine',
+        '-traffic-engine'
+    ]
+    image_params = {'controller': {'pull-policy': 'Never'}}
+    spec_patch = {
+        'spec': {
+            'update_policy': 'auto',
+            'image_pull_policy': {'*': 'Always', 'traffic-engine': 'Never'}
+        }
+    }
+    try:
+        op_rscount = utils.get_operator_restart_count()
+        print("[Namespace:{}]Deploying KNE topology".format(
+            namespace1
+        ))
+        utils.load_image_configmap(image_params)
+        utils.create_kne_config(namespace1_config, namespace1)
+        utils.ixia_c_pods_ok(namespace1, expected_pods)
+        utils.check_pull_policy('ixia-c', expected_pods[0], namespace1, 'Never')
+        utils.check_pull_policy('gnmi', expected_pods[0], namespace1, 'IfNotPresent')
+
+        print("[Namespace:{}]Updating ixiatg image pull policy".format(
+            namespace1
+        ))
+        utils.patch_ixiatg(namespace1, 'otg', spec_patch)
+        utils.pull_policy_ok('ixia-c', expected_pods[0], namespace1, 'Always')
+        utils.pull_policy_ok('gnmi', expected_pods[0], namespace1, 'Always')
+        utils.pull_policy_ok(expected_pods[1]+container_extensions[0], expected_pods[1], namespace1, 'Always')
+        utils.pull_policy_ok(expected_pods[1]+container_extensions[1], expected_pods[1], namespace1, 'Never')
+        utils.ixia_c_pods_ok(namespace1, expected_pods)
+        op_rscount = utils.ixia_c_operator_ok(op_rscount)
+
+        print("[Namespace:{}]Deleting KNE topology".format(
+            namespace1
+        ))
+        utils.delete_kne_config(namespace1_config, namespace1)
+        utils.ixia_c_pods_ok(namespace1, [])
+        op_rscount = utils.ixia_c_operator_ok(op_rscount)
+        utils.reset_configmap()
+
+    finally:
+        utils.delete_kne_config(namespace1_config, namespace1)
+        utils.ixia_c_pods_ok(namespace1, [])
+        utils.reset_configmap()
+
+        utils.wait_for(
+            lambda: utils.topology_deleted(namespace1),
+            'topology deleted',
+            timeout_seconds=30
+        )
+        time.sleep(5)
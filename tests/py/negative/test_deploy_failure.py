@@ -0,0 +1,65 @@
+import pytest
+import utils
+import time
+
+@pytest.mark.negative
+def test_deploy_failure():
+    """
+    Deploy pd kne topology with BAD traffic engine image,
+    - namespace - 1: ixia-c
+    Delete pd kne topology,
+    - namespace - 1: ixia-c
+    Deploy pd kne topology with BAD controller image,
+    - namespace - 1: ixia-c
+    Delete pd kne topology,
+    - namespace - 1: ixia-c
+    Validate,
+    - ixiatg state FAILED without waiting for deploy timeout
+    - failure reason naming the failed pod, container and image pull failure
+    - operator pod health
+    """
+    namespace1 = 'ixia-c'
+    namespace1_config = 'ixia_c_pd_topology.yaml'
+    name = 'otg'
+    bad_components = [
+        ('traffic-engine', 'otg-port-eth1', 'container otg-port-eth1-traffic-engine failed'),
+        ('controller', 'otg-controller', 'container ixia-c failed'),
+    ]
+
+    try:
+        op_rscount = utils.get_operator_restart_count()
+        for component, pod, container_reason in bad_components:
+            print("[Namespace:{}]Deploying KNE topology with bad {}".format(
+                namespace1, component
+            ))
+            utils.load_bad_configmap(component)
+            utils.create_kne_config(namespace1_config, namespace1, False)
+            # Wait for topology to be created
+            time.sleep(10)
+            utils.ixiatg_state_ok(namespace1, name, 'FAILED', timeout_seconds=180)
+            reason = utils.get_ixiatg_reason(namespace1, name)
+            assert pod in reason and container_reason in reason and 'ImagePullFailed' in reason, \
+                "Unexpected failure reason {}".format(reason)
+            op_rscount = utils.ixia_c_operator_ok(op_rscount)
+
+            print("[Namespace:{}]Deleting KNE topology".format(
+                namespace1
+            ))
+            utils.delete_kne_config(namespace1_config, namespace1)
+            utils.ixia_c_pods_ok(namespace1, [])
+            op_rscount = utils.ixia_c_operator_ok(op_rscount)
+            utils.reset_configmap()
+
+            # Wait for topology to be deleted
+            time.sleep(10)
+
+    finally:
+        utils.delete_kne_config(namespace1_config, namespace1)
+        utils.ixia_c_pods_ok(namespace1, [])
+        utils.reset_configmap()
+        utils.wait_for(
+            lambda: utils.topology_deleted(namespace1),
+            'topology deleted',
+            timeout_seconds=30
+        )
+        time.sleep(5)
@@ -155,8 +155,8 @@ def load_custom_configmap():
 
 
 def ixia_c_custom_pods_ok(namespace):
-    cmd = "kubectl get pod/otg-controller -n {} -o yaml".format(
-        namespace
+    cmd = "kubectl get pod/{} -n {} -o yaml".format(
+        get_pod_name("otg-controller", namespace), namespace
     )
     out, _ = exec_shell(cmd, True, False)
     yaml_obj = yaml.safe_load(out)
@@ -253,6 +253,27 @@ def load_liveness_configmap(probe):
     os.remove(custom_configmap_path)
 
 
+def load_image_configmap(image_params):
+    print("Loading custom image config...")
+    cmd = "cat ./{}".format(
+        IXIA_CONFIG_MAP_FILE
+    )
+    out, _ = exec_shell(cmd, False, True)
+    yaml_obj = yaml.safe_load(out)
+    json_obj = json.loads(yaml_obj["data"]["versions"])
+    for elem in json_obj["images"]:
+        if elem["name"] in image_params.keys():
+            for key in image_params[elem["name"]]:
+                elem[key] = image_params[elem["name"]][key]
+    yaml_obj["data"]["versions"] = json.dumps(json_obj)
+    custom_configmap_path = "{}".format(CUSTOM_CONFIG_MAP_FILE)
+    with open(custom_configmap_path, "w") as yaml_file:
+        yaml.dump(yaml_obj, yaml_file)
+
+    apply_configmap(custom_configmap_path)
+    os.remove(custom_configmap_path)
+
+
 def load_min_resource_configmap(resource):
     print("Loading custom min resource config...")
     cmd = "cat ./{}".format(
@@ -328,6 +349,20 @@ def remove_license_secrets():
         print("Secret license-server deleted inside kind container")
 
 
+def get_pod_name(pod, namespace):
+    """
+    Returns the actual pod name for pods managed by a deployment (e.g. otg-controller),
+    located through the app label; otherwise returns the name as is.
+    """
+    cmd = "kubectl get pods -n {} -l app={} -o jsonpath='{{.items[0].metadata.name}}'".format(
+        namespace, pod
+    )
+    out, _ = exec_shell(cmd, True, True)
+    if out is None or out == "":
+        return pod
+    return out
+
+
 def seconds_elapsed(start_seconds):
     return int(round(time.time() - start_seconds))
 
@@ -392,7 +427,7 @@ def pods_count_ok(exp_pods, namespace):
 
 def pod_status_ok(namespace, pod, status):
     cmd = "kubectl get pod/{} -n {} | grep {} | wc -l".format(
-        pod, namespace, status
+        get_pod_name(pod, namespace), namespace, status
     )
     out, _ = exec_shell(cmd, True, False)
     out = out.split('\n')
@@ -405,7 +440,7 @@ def pod_status_ok(namespace, pod, status):
 
 def containers_count_ok(num_containers, pod, namespace):
     cmd = "kubectl get pod/{} -n {} | grep -v RESTARTS".format(
-        pod, namespace
+        get_pod_name(pod, namespace), namespace
     )
     out, _ = exec_shell(cmd, True, False)
     out = out.split()
@@ -446,7 +481,7 @@ def pods_status_ok(exp_pods, namespace):
 
 def pod_exists(podname, namespace):
     cmd = "kubectl describe pods/{} -n {}".format(
-        podname, namespace
+        get_pod_name(podname, namespace), namespace
     )
     out, _ = exec_shell(cmd, True, True)
     if out is None:
@@ -567,7 +602,7 @@ def check_probe_data(cont, pod, namespace, liveness=True, enabled=True, delay=0,
         base_cmd = base_cmd + "livenessProbe"
     else:
         base_cmd = base_cmd + "startupProbe"
-    base_cmd = "kubectl get pod/{} -n {} -o ".format(pod, namespace) + base_cmd
+    base_cmd = "kubectl get pod/{} -n {} -o ".format(get_pod_name(pod, namespace), namespace) + base_cmd
     cmd = base_cmd + "}'"
     out, _ = exec_shell(cmd, True, True)
     if enabled:
@@ -589,7 +624,7 @@ def check_probe_data(cont, pod, namespace, liveness=True, enabled=True, delay=0,
 
 def check_env_data(cont, pod, namespace, name, value):
     base_cmd = "'jsonpath={.spec.containers[?(@.name==\"" + cont + "\")].env"
-    base_cmd = "kubectl get pod/{} -n {} -o ".format(pod, namespace) + base_cmd
+    base_cmd = "kubectl get pod/{} -n {} -o ".format(get_pod_name(pod, namespace), namespace) + base_cmd
     cmd = base_cmd + "}'"
     out, _ = exec_shell(cmd, True, True)
     res = json.loads(out)
@@ -602,9 +637,33 @@ def check_env_data(cont, pod, namespace, name, value):
     raise Exception("Failed to find environment variable '" + name + "'")
 
 
+def get_pull_policy(cont, pod, namespace):
+    base_cmd = "'jsonpath={.spec.containers[?(@.name==\"" + cont + "\")].imagePullPolicy"
+    base_cmd = "kubectl get pod/{} -n {} -o ".format(get_pod_name(pod, namespace), namespace) + base_cmd
+    cmd = base_cmd + "}'"
+    out, _ = exec_shell(cmd, True, True)
+    return out
+
+
+def check_pull_policy(cont, pod, namespace, policy):
+    out = get_pull_policy(cont, pod, namespace)
+    assert out == policy, "Image pull policy mismatch, expected {}, found {}".format(policy, out)
+
+
+def pull_policy_ok(cont, pod, namespace, policy):
+    print("[Namespace:{}]Verifying pull policy {} of {} in pod {}".format(
+        namespace, policy, cont, pod
+    ))
+    wait_for(
+        lambda: get_pull_policy(cont, pod, namespace) == policy,
+        'pull policy to be as expected',
+        timeout_seconds=120
+    )
+
+
 def check_min_resource_data(cont, pod, namespace, memory="", cpu=""):
     base_cmd = "'jsonpath={.spec.containers[?(@.name==\"" + cont + "\")].resources.requests"
-    base_cmd = "kubectl get pod/{} -n {} -o ".format(pod, namespace) + base_cmd
+    base_cmd = "kubectl get pod/{} -n {} -o ".format(get_pod_name(pod, namespace), namespace) + base_cmd
     cmd = base_cmd + "}'"
     out, _ = exec_shell(cmd, True, True)
     print(out)
@@ -775,3 +834,91 @@ def delete_namespace(namespace):
     exec_shell(cmd, True, True)
     
 
+OPERATOR_NAMESPACE = 'ixiatg-op-system'
+OPERATOR_DEPLOYMENT = 'ixiatg-op-controller-manager'
+OPERATOR_DEFAULT_ARGS = ['--leader-elect']
+
+
+def set_operator_args(args=[]):
+    """
+    Replaces the arguments of the operator manager container with the
+    default ones followed by args and waits for the operator rollout.
+    """
+    patch = {
+        "spec": {"template": {"spec": {"containers": [
+            {"name": "manager", "args": OPERATOR_DEFAULT_ARGS + args}
+        ]}}}
+    }
+    cmd = "kubectl patch deployment {} -n {} -p '{}'".format(
+        OPERATOR_DEPLOYMENT, OPERATOR_NAMESPACE, json.dumps(patch)
+    )
+    out, _ = exec_shell(cmd, True, True)
+    if out is None:
+        raise Exception("Failed to set operator args {}".format(args))
+    cmd = "kubectl rollout status deployment {} -n {} --timeout=120s".format(
+        OPERATOR_DEPLOYMENT, OPERATOR_NAMESPACE
+    )
+    out, _ = exec_shell(cmd, True, True)
+    if out is None:
+        raise Exception("Operator not rolled out with args {}".format(args))
+
+
+def get_ixiatg_state(namespace, name):
+    cmd = "kubectl get ixiatg {} -n {} -o 'jsonpath={{.status.state}}'".format(
+        name, namespace
+    )
+    out, _ = exec_shell(cmd, True, True)
+    return out
+
+
+def get_ixiatg_reason(namespace, name):
+    cmd = "kubectl get ixiatg {} -n {} -o 'jsonpath={{.status.reason}}'".format(
+        name, namespace
+    )
+    out, _ = exec_shell(cmd, True, True)
+    return out
+
+
+def ixiatg_state_ok(namespace, name, state, timeout_seconds=300):
+    wait_for(
+        lambda: get_ixiatg_state(namespace, name) == state,
+        'ixiatg {} state {}'.format(name, state),
+        timeout_seconds=timeout_seconds
+    )
+
+
+def check_image(cont, pod, namespace, image):
+    base_cmd = "'jsonpath={.spec.containers[?(@.name==\"" + cont + "\")].image"
+    base_cmd = "kubectl get pod/{} -n {} -o ".format(get_pod_name(pod, namespace), namespace) + base_cmd
+    cmd = base_cmd + "}'"
+    out, _ = exec_shell(cmd, True, True)
+    assert out == image, "Image mismatch, expected {}, found {}".format(image, out)
+
+
+def patch_ixiatg(namespace, name, patch):
+    cmd = "kubectl patch ixiatg {} -n {} --type merge -p '{}'".format(
+        name, namespace, json.dumps(patch)
+    )
+    out, _ = exec_shell(cmd, True, True)
+    if out is None:
+        raise Exception("Failed to patch ixiatg {} with {}".format(name, patch))
+
+
+def listening_on_any(cont, pod, namespace, port):
+    """
+    Returns whether a socket of the pod listens on port of the IPv6 any
+    address "::", accepting IPv4 and IPv6 connections.
+    """
+    cmd = "kubectl exec {} -n {} -c {} -- cat /proc/net/tcp6".format(
+        get_pod_name(pod, namespace), namespace, cont
+    )
+    out, _ = exec_shell(cmd, True, True)
+    if out is None:
+        return False
+    local = "{}:{:04X}".format("0" * 32, port)
+    for line in out.splitlines()[1:]:
+        fields = line.split()
+        # Local address and state, 0A being LISTEN
+        if len(fields) > 3 and fields[1] == local and fields[3] == '0A':
+            return True
+    return False